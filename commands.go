@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"webscrapper/models"
+)
+
+// usage describes the maintenance commands
+const usage = `usage: webscrapper [command]
+
+Without a command the HTTP server is started.
+
+Commands:
+  create-org <name> <admin-username>    create an organization administered by
+                                        an existing user
+  set-role <username> <role> [org-id]   assign a role (candidate, recruiter, admin)
+                                        and organization to an existing user`
+
+// runCommand executes a maintenance command against the configured database
+func runCommand(args []string) error {
+	switch args[0] {
+	case "set-role":
+		if len(args) < 3 || len(args) > 4 {
+			return fmt.Errorf("set-role needs a username, a role and an optional org ID\n\n%s", usage)
+		}
+		orgID := ""
+		if len(args) == 4 {
+			orgID = args[3]
+		}
+		user, err := models.AssignRole(args[1], args[2], orgID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("User %s is now %s (org %q)\n", user.Username, user.Role, user.OrgID)
+		return nil
+	case "create-org":
+		if len(args) != 3 {
+			return fmt.Errorf("create-org needs a name and an admin username\n\n%s", usage)
+		}
+		user, err := models.GetUserByUsername(args[2])
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return fmt.Errorf("user %q not found", args[2])
+		}
+		org, err := models.CreateOrganization(args[1], user.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created organization %s (%s) with admin %s\n", org.Name, org.ID, user.Username)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+	}
+}
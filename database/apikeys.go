@@ -0,0 +1,134 @@
+package database
+
+import (
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// APIKey is a long-lived credential for machine-to-machine access.
+// Only the SHA-256 hash of the key is stored; Prefix is kept for display.
+type APIKey struct {
+	ID        string
+	Hash      string
+	Prefix    string
+	Name      string
+	UserID    string
+	OrgID     string
+	Scopes    []string
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// Bucket names for API keys in the bolt store
+var (
+	apiKeysBucket       = []byte("api_keys")     // key ID -> APIKey
+	apiKeysByHashBucket = []byte("idx_api_keys") // key hash -> key ID
+)
+
+// SaveAPIKey stores an API key
+func (s *MemoryStore) SaveAPIKey(key *APIKey) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stored := *key
+	s.apiKeys[key.ID] = &stored
+	s.apiKeysByHash[key.Hash] = key.ID
+	return nil
+}
+
+// GetAPIKeyByID retrieves an API key by ID
+func (s *MemoryStore) GetAPIKeyByID(id string) (*APIKey, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	key, exists := s.apiKeys[id]
+	if !exists {
+		return nil, nil
+	}
+	copied := *key
+	return &copied, nil
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of its secret
+func (s *MemoryStore) GetAPIKeyByHash(hash string) (*APIKey, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	key, exists := s.apiKeys[s.apiKeysByHash[hash]]
+	if !exists {
+		return nil, nil
+	}
+	copied := *key
+	return &copied, nil
+}
+
+// GetAPIKeys retrieves the API keys of an organization, or of a single user
+// within it when userID is not empty
+func (s *MemoryStore) GetAPIKeys(orgID, userID string) ([]*APIKey, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var keys []*APIKey
+	for _, key := range s.apiKeys {
+		if key.OrgID == orgID && (userID == "" || key.UserID == userID) {
+			copied := *key
+			keys = append(keys, &copied)
+		}
+	}
+	return keys, nil
+}
+
+// SaveAPIKey stores an API key
+func (s *BoltStore) SaveAPIKey(key *APIKey) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := put(tx, apiKeysBucket, key.ID, key); err != nil {
+			return err
+		}
+		return tx.Bucket(apiKeysByHashBucket).Put([]byte(key.Hash), []byte(key.ID))
+	})
+}
+
+// getAPIKey runs a single-key lookup in a read transaction
+func (s *BoltStore) getAPIKey(fn func(tx *bolt.Tx, key *APIKey) (bool, error)) (*APIKey, error) {
+	var key APIKey
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		found, err = fn(tx, &key)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetAPIKeyByID retrieves an API key by ID
+func (s *BoltStore) GetAPIKeyByID(id string) (*APIKey, error) {
+	return s.getAPIKey(func(tx *bolt.Tx, key *APIKey) (bool, error) {
+		return get(tx, apiKeysBucket, id, key)
+	})
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of its secret
+func (s *BoltStore) GetAPIKeyByHash(hash string) (*APIKey, error) {
+	return s.getAPIKey(func(tx *bolt.Tx, key *APIKey) (bool, error) {
+		return lookup(tx, apiKeysByHashBucket, apiKeysBucket, hash, key)
+	})
+}
+
+// GetAPIKeys retrieves the API keys of an organization, or of a single user
+// within it when userID is not empty
+func (s *BoltStore) GetAPIKeys(orgID, userID string) ([]*APIKey, error) {
+	var keys []*APIKey
+	err := s.db.View(func(tx *bolt.Tx) error {
+		all, err := loadAll[APIKey](tx, apiKeysBucket)
+		for _, key := range all {
+			if key.OrgID == orgID && (userID == "" || key.UserID == userID) {
+				keys = append(keys, key)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// AuditEvent is an entry of the append-only audit trail. Seq is assigned
+// by the store and increases with every event.
+type AuditEvent struct {
+	Seq        uint64
+	Time       time.Time
+	Action     string
+	ActorID    string // Empty for anonymous callers and the system itself
+	OrgID      string // Organization the event belongs to
+	TargetType string
+	TargetID   string
+	IP         string
+	UserAgent  string
+	Details    map[string]string
+}
+
+// AuditFilter selects audit events. Zero fields match everything.
+type AuditFilter struct {
+	OrgID    string
+	AllOrgs  bool // Ignore OrgID and match every organization
+	Action   string
+	ActorID  string
+	TargetID string
+	Since    time.Time
+	Until    time.Time
+	Before   uint64 // Only events with a smaller Seq, for paging
+	Limit    int
+}
+
+// Matches reports whether an event passes the filter, ignoring Before and Limit
+func (f *AuditFilter) Matches(event *AuditEvent) bool {
+	switch {
+	case !f.AllOrgs && event.OrgID != f.OrgID:
+		return false
+	case f.Action != "" && event.Action != f.Action:
+		return false
+	case f.ActorID != "" && event.ActorID != f.ActorID:
+		return false
+	case f.TargetID != "" && event.TargetID != f.TargetID:
+		return false
+	case !f.Since.IsZero() && event.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !event.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// auditEventsBucket holds audit events in the bolt store
+var auditEventsBucket = []byte("audit_events") // big-endian seq -> AuditEvent
+
+// AppendAuditEvent adds an event to the audit trail and sets its Seq
+func (s *MemoryStore) AppendAuditEvent(event *AuditEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	event.Seq = uint64(len(s.auditEvents)) + 1
+	stored := *event
+	s.auditEvents = append(s.auditEvents, &stored)
+	return nil
+}
+
+// GetAuditEvents returns matching audit events, newest first
+func (s *MemoryStore) GetAuditEvents(filter AuditFilter) ([]*AuditEvent, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var events []*AuditEvent
+	for i := len(s.auditEvents) - 1; i >= 0; i-- {
+		event := s.auditEvents[i]
+		if filter.Before != 0 && event.Seq >= filter.Before {
+			continue
+		}
+		if !filter.Matches(event) {
+			continue
+		}
+		copied := *event
+		events = append(events, &copied)
+		if filter.Limit > 0 && len(events) == filter.Limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+// AppendAuditEvent adds an event to the audit trail and sets its Seq
+func (s *BoltStore) AppendAuditEvent(event *AuditEvent) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(auditEventsBucket)
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		event.Seq = seq
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(auditKey(seq), data)
+	})
+}
+
+// GetAuditEvents returns matching audit events, newest first
+func (s *BoltStore) GetAuditEvents(filter AuditFilter) ([]*AuditEvent, error) {
+	var events []*AuditEvent
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(auditEventsBucket).Cursor()
+		var k, v []byte
+		if filter.Before != 0 {
+			// Seek lands on Before itself or the next key; step back from there
+			k, _ = c.Seek(auditKey(filter.Before))
+			if k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		} else {
+			k, v = c.Last()
+		}
+		for ; k != nil; k, v = c.Prev() {
+			var event AuditEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				return err
+			}
+			if !filter.Matches(&event) {
+				continue
+			}
+			events = append(events, &event)
+			if filter.Limit > 0 && len(events) == filter.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// auditKey encodes an audit sequence number so keys sort in order
+func auditKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
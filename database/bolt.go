@@ -0,0 +1,374 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket names used by the bolt store
+var (
+	usersBucket   = []byte("users")
+	resumesBucket = []byte("resumes")
+
+	// Secondary indexes, maintained in the same transaction as every write
+	usersByUsernameBucket = []byte("idx_users_username") // username -> user ID
+	usersByEmailBucket    = []byte("idx_users_email")    // normalized email -> user ID
+	usersByOrgBucket      = []byte("idx_users_org")      // org ID \x00 user ID -> nil
+	usersByOIDCBucket     = []byte("idx_users_oidc")     // issuer \x00 subject -> user ID
+	resumesByUserBucket   = []byte("idx_resumes_user")   // user ID \x00 resume ID -> nil
+	resumesByOrgBucket    = []byte("idx_resumes_org")    // org ID \x00 resume ID -> nil
+	resumesByHashBucket   = []byte("idx_resumes_hash")   // org ID \x00 content hash -> resume ID
+)
+
+// BoltStore persists records in a single bbolt file.
+// Records are stored as JSON keyed by ID.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens (or creates) the bolt database at path.
+// Buckets are created by Migrate, which InitDB runs before first use.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Close closes the underlying bolt file
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// put encodes v as JSON and stores it under key in bucket
+func put(tx *bolt.Tx, bucket []byte, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucket).Put([]byte(key), data)
+}
+
+// get decodes the record stored under key into v and reports whether it exists
+func get(tx *bolt.Tx, bucket []byte, key string, v interface{}) (bool, error) {
+	data := tx.Bucket(bucket).Get([]byte(key))
+	if data == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// lookup follows an index entry to the record it points at
+func lookup(tx *bolt.Tx, index, bucket []byte, key string, v interface{}) (bool, error) {
+	id := tx.Bucket(index).Get([]byte(key))
+	if id == nil {
+		return false, nil
+	}
+	return get(tx, bucket, string(id), v)
+}
+
+// setKey builds the key of a one-to-many index entry
+func setKey(key, id string) []byte {
+	return []byte(key + "\x00" + id)
+}
+
+// setMembers returns the IDs stored under key in a one-to-many index
+func setMembers(tx *bolt.Tx, index []byte, key string) []string {
+	prefix := setKey(key, "")
+	var ids []string
+	c := tx.Bucket(index).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		ids = append(ids, string(k[len(prefix):]))
+	}
+	return ids
+}
+
+// indexUser adds a user's index entries
+func indexUser(tx *bolt.Tx, user *User) error {
+	if err := tx.Bucket(usersByOrgBucket).Put(setKey(user.OrgID, user.ID), nil); err != nil {
+		return err
+	}
+	if err := tx.Bucket(usersByUsernameBucket).Put([]byte(user.Username), []byte(user.ID)); err != nil {
+		return err
+	}
+	if user.OIDCSubject != "" {
+		if err := tx.Bucket(usersByOIDCBucket).Put([]byte(oidcKey(user.OIDCIssuer, user.OIDCSubject)), []byte(user.ID)); err != nil {
+			return err
+		}
+	}
+	if user.Email == "" {
+		return nil
+	}
+	return tx.Bucket(usersByEmailBucket).Put([]byte(NormalizeEmail(user.Email)), []byte(user.ID))
+}
+
+// unindexUser removes a user's index entries if they still point at it
+func unindexUser(tx *bolt.Tx, user *User) error {
+	if err := tx.Bucket(usersByOrgBucket).Delete(setKey(user.OrgID, user.ID)); err != nil {
+		return err
+	}
+	if err := deleteIfOwned(tx.Bucket(usersByUsernameBucket), []byte(user.Username), user.ID); err != nil {
+		return err
+	}
+	if err := deleteIfOwned(tx.Bucket(usersByOIDCBucket), []byte(oidcKey(user.OIDCIssuer, user.OIDCSubject)), user.ID); err != nil {
+		return err
+	}
+	return deleteIfOwned(tx.Bucket(usersByEmailBucket), []byte(NormalizeEmail(user.Email)), user.ID)
+}
+
+// indexResume adds a resume's index entries
+func indexResume(tx *bolt.Tx, resume *Resume) error {
+	if err := tx.Bucket(resumesByUserBucket).Put(setKey(resume.UserID, resume.ID), nil); err != nil {
+		return err
+	}
+	if err := tx.Bucket(resumesByOrgBucket).Put(setKey(resume.OrgID, resume.ID), nil); err != nil {
+		return err
+	}
+	if resume.ContentHash == "" {
+		return nil
+	}
+	return tx.Bucket(resumesByHashBucket).Put([]byte(hashKey(resume.OrgID, resume.ContentHash)), []byte(resume.ID))
+}
+
+// unindexResume removes a resume's index entries if they still point at it
+func unindexResume(tx *bolt.Tx, resume *Resume) error {
+	if err := tx.Bucket(resumesByUserBucket).Delete(setKey(resume.UserID, resume.ID)); err != nil {
+		return err
+	}
+	if err := tx.Bucket(resumesByOrgBucket).Delete(setKey(resume.OrgID, resume.ID)); err != nil {
+		return err
+	}
+	return deleteIfOwned(tx.Bucket(resumesByHashBucket), []byte(hashKey(resume.OrgID, resume.ContentHash)), resume.ID)
+}
+
+// deleteIfOwned deletes an index entry only when it points at id
+func deleteIfOwned(b *bolt.Bucket, key []byte, id string) error {
+	if len(key) == 0 || !bytes.Equal(b.Get(key), []byte(id)) {
+		return nil
+	}
+	return b.Delete(key)
+}
+
+// SaveUser saves a user to the bolt database.
+// It returns a *ConflictError if the username or email belongs to another user.
+func (s *BoltStore) SaveUser(user *User) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if id := tx.Bucket(usersByUsernameBucket).Get([]byte(user.Username)); id != nil && string(id) != user.ID {
+			return &ConflictError{Field: "username"}
+		}
+		if user.Email != "" {
+			id := tx.Bucket(usersByEmailBucket).Get([]byte(NormalizeEmail(user.Email)))
+			if id != nil && string(id) != user.ID {
+				return &ConflictError{Field: "email"}
+			}
+		}
+		if user.OIDCSubject != "" {
+			id := tx.Bucket(usersByOIDCBucket).Get([]byte(oidcKey(user.OIDCIssuer, user.OIDCSubject)))
+			if id != nil && string(id) != user.ID {
+				return &ConflictError{Field: "oidc_subject"}
+			}
+		}
+
+		var old User
+		found, err := get(tx, usersBucket, user.ID, &old)
+		if err != nil {
+			return err
+		}
+		if found {
+			if err := unindexUser(tx, &old); err != nil {
+				return err
+			}
+		}
+		if err := put(tx, usersBucket, user.ID, user); err != nil {
+			return err
+		}
+		return indexUser(tx, user)
+	})
+}
+
+// getUser runs a single-user lookup in a read transaction
+func (s *BoltStore) getUser(fn func(tx *bolt.Tx, user *User) (bool, error)) (*User, error) {
+	var user User
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		found, err = fn(tx, &user)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByID retrieves a user by ID
+func (s *BoltStore) GetUserByID(id string) (*User, error) {
+	return s.getUser(func(tx *bolt.Tx, user *User) (bool, error) {
+		return get(tx, usersBucket, id, user)
+	})
+}
+
+// GetUserByUsername retrieves a user by username
+func (s *BoltStore) GetUserByUsername(username string) (*User, error) {
+	return s.getUser(func(tx *bolt.Tx, user *User) (bool, error) {
+		return lookup(tx, usersByUsernameBucket, usersBucket, username, user)
+	})
+}
+
+// GetUserByEmail retrieves a user by email, ignoring case
+func (s *BoltStore) GetUserByEmail(email string) (*User, error) {
+	return s.getUser(func(tx *bolt.Tx, user *User) (bool, error) {
+		return lookup(tx, usersByEmailBucket, usersBucket, NormalizeEmail(email), user)
+	})
+}
+
+// GetUserByOIDCSubject retrieves the user linked to an external identity
+func (s *BoltStore) GetUserByOIDCSubject(issuer, subject string) (*User, error) {
+	return s.getUser(func(tx *bolt.Tx, user *User) (bool, error) {
+		return lookup(tx, usersByOIDCBucket, usersBucket, oidcKey(issuer, subject), user)
+	})
+}
+
+// GetUsersByOrgID retrieves all users in an organization
+func (s *BoltStore) GetUsersByOrgID(orgID string) ([]*User, error) {
+	var users []*User
+	err := s.db.View(func(tx *bolt.Tx) error {
+		for _, id := range setMembers(tx, usersByOrgBucket, orgID) {
+			var user User
+			found, err := get(tx, usersBucket, id, &user)
+			if err != nil {
+				return err
+			}
+			if found {
+				users = append(users, &user)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// DeleteUser removes a user. Deleting a missing user is not an error.
+func (s *BoltStore) DeleteUser(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var user User
+		found, err := get(tx, usersBucket, id, &user)
+		if err != nil || !found {
+			return err
+		}
+		if err := unindexUser(tx, &user); err != nil {
+			return err
+		}
+		return tx.Bucket(usersBucket).Delete([]byte(id))
+	})
+}
+
+// SaveResume saves a resume to the bolt database
+func (s *BoltStore) SaveResume(resume *Resume) error {
+	if resume.ContentHash == "" {
+		resume.ContentHash = ContentHash(resume.Content)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var old Resume
+		found, err := get(tx, resumesBucket, resume.ID, &old)
+		if err != nil {
+			return err
+		}
+		if found {
+			if err := unindexResume(tx, &old); err != nil {
+				return err
+			}
+		}
+		if err := put(tx, resumesBucket, resume.ID, resume); err != nil {
+			return err
+		}
+		return indexResume(tx, resume)
+	})
+}
+
+// getResume runs a single-resume lookup in a read transaction
+func (s *BoltStore) getResume(fn func(tx *bolt.Tx, resume *Resume) (bool, error)) (*Resume, error) {
+	var resume Resume
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		found, err = fn(tx, &resume)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &resume, nil
+}
+
+// GetResumeByID retrieves a resume by ID within an organization
+func (s *BoltStore) GetResumeByID(orgID, id string) (*Resume, error) {
+	return s.getResume(func(tx *bolt.Tx, resume *Resume) (bool, error) {
+		found, err := get(tx, resumesBucket, id, resume)
+		return found && resume.OrgID == orgID, err
+	})
+}
+
+// GetResumeByContentHash retrieves a resume with the given content hash
+// within an organization
+func (s *BoltStore) GetResumeByContentHash(orgID, hash string) (*Resume, error) {
+	return s.getResume(func(tx *bolt.Tx, resume *Resume) (bool, error) {
+		return lookup(tx, resumesByHashBucket, resumesBucket, hashKey(orgID, hash), resume)
+	})
+}
+
+// getResumes loads every resume of an organization listed under key in a
+// one-to-many index
+func (s *BoltStore) getResumes(orgID string, index []byte, key string) ([]*Resume, error) {
+	var resumes []*Resume
+	err := s.db.View(func(tx *bolt.Tx) error {
+		for _, id := range setMembers(tx, index, key) {
+			var resume Resume
+			found, err := get(tx, resumesBucket, id, &resume)
+			if err != nil {
+				return err
+			}
+			if found && resume.OrgID == orgID {
+				resumes = append(resumes, &resume)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resumes, nil
+}
+
+// GetResumesByUserID retrieves all resumes for a user within an organization
+func (s *BoltStore) GetResumesByUserID(orgID, userID string) ([]*Resume, error) {
+	return s.getResumes(orgID, resumesByUserBucket, userID)
+}
+
+// GetResumesByOrgID retrieves all resumes owned by an organization
+func (s *BoltStore) GetResumesByOrgID(orgID string) ([]*Resume, error) {
+	return s.getResumes(orgID, resumesByOrgBucket, orgID)
+}
+
+// DeleteResume removes a resume within an organization.
+// Deleting a missing resume is not an error.
+func (s *BoltStore) DeleteResume(orgID, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var resume Resume
+		found, err := get(tx, resumesBucket, id, &resume)
+		if err != nil || !found || resume.OrgID != orgID {
+			return err
+		}
+		if err := unindexResume(tx, &resume); err != nil {
+			return err
+		}
+		return tx.Bucket(resumesBucket).Delete([]byte(id))
+	})
+}
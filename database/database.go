@@ -1,31 +1,62 @@
 package database
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
-	"sync"
+	"strings"
 	"time"
 )
 
-// In-memory database implementation
-var (
-	users   = make(map[string]*User)
-	resumes = make(map[string]*Resume)
-	mutex   = &sync.RWMutex{}
+// Supported storage drivers
+const (
+	DriverMemory = "memory"
+	DriverBolt   = "bolt"
 )
 
-// User represents a user in the in-memory database
+// User represents a user in the database
 type User struct {
 	ID        string
 	Username  string
 	Password  string
 	Email     string
+	Role      string
+	OrgID     string
 	CreatedAt time.Time
+
+	DisplayName string
+
+	// Set once the user follows a verification link or signs in with an
+	// identity provider that vouches for the address
+	EmailVerified bool
+
+	// Refresh tokens issued before this are rejected
+	PasswordChangedAt time.Time
+
+	// Access and refresh tokens issued before this are rejected. Set when
+	// an admin changes the user's role or removes them from their
+	// organization, since tokens carry both.
+	SessionsRevokedAt time.Time
+
+	// External identity linked through OpenID Connect, if any
+	OIDCIssuer  string
+	OIDCSubject string
+
+	// TOTP second factor. The secret is set on enrollment and only used once
+	// TOTPEnabled; recovery codes are stored as SHA-256 hashes.
+	TOTPSecret      string
+	TOTPEnabled     bool
+	TOTPLastCounter uint64 // Newest time step accepted, so codes cannot be replayed
+	RecoveryCodes   []string
 }
 
-// Resume represents a resume in the in-memory database
+// Resume represents a resume in the database
 type Resume struct {
 	ID         string
 	UserID     string
+	OrgID      string
 	Filename   string
 	Content    string
 	Keywords   []string
@@ -33,78 +64,166 @@ type Resume struct {
 	Education  []string
 	Experience []string
 	UploadedAt time.Time
+
+	// Metadata set by the owner after upload
+	Title         string
+	CandidateName string
+	Tags          []string
+
+	// Uploaded file under ./uploads; empty for resumes stored before paths
+	// were recorded
+	FilePath string
+
+	// ContentHash is filled in by the store when empty, see ContentHash
+	ContentHash string
 }
 
-// InitDB initializes the in-memory database
-func InitDB() {
-	log.Println("Using in-memory database (SQLite not available)")
-	// Nothing to do for in-memory database
+// Store is the storage backend used by the models layer.
+// Lookups return (nil, nil) when no record matches.
+type Store interface {
+	SaveUser(user *User) error
+	GetUserByID(id string) (*User, error)
+	GetUserByUsername(username string) (*User, error)
+	GetUserByEmail(email string) (*User, error)
+	GetUserByOIDCSubject(issuer, subject string) (*User, error)
+	GetUsersByOrgID(orgID string) ([]*User, error)
+	DeleteUser(id string) error
+
+	// Resume queries are scoped to an organization (tenant); the empty org
+	// ID is the tenant of users that belong to no organization
+	SaveResume(resume *Resume) error
+	GetResumeByID(orgID, id string) (*Resume, error)
+	GetResumeByContentHash(orgID, hash string) (*Resume, error)
+	GetResumesByUserID(orgID, userID string) ([]*Resume, error)
+	GetResumesByOrgID(orgID string) ([]*Resume, error)
+	DeleteResume(orgID, id string) error
+
+	SaveOrganization(org *Organization) error
+	GetOrganizationByID(id string) (*Organization, error)
+	GetOrganizations() ([]*Organization, error)
+	SaveInvitation(invitation *Invitation) error
+	GetInvitation(hash string) (*Invitation, error)
+	UseInvitation(hash string) (*Invitation, error)
+
+	SaveAPIKey(key *APIKey) error
+	GetAPIKeyByID(id string) (*APIKey, error)
+	GetAPIKeyByHash(hash string) (*APIKey, error)
+	GetAPIKeys(orgID, userID string) ([]*APIKey, error)
+
+	SaveJob(job *Job) error
+	GetJobByID(orgID, id string) (*Job, error)
+	GetJobs(orgID, ownerID string) ([]*Job, error)
+	DeleteJob(orgID, id string) error
+
+	// The audit trail is append-only
+	AppendAuditEvent(event *AuditEvent) error
+	GetAuditEvents(filter AuditFilter) ([]*AuditEvent, error)
+
+	SaveRefreshToken(token *RefreshToken) error
+	UseRefreshToken(hash string) (*RefreshToken, error)
+	RevokeRefreshTokenFamily(familyID string) error
+	RevokeAccessToken(jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(jti string) (bool, error)
+
+	Close() error
 }
 
-// CloseDB closes the database connection
-func CloseDB() {
-	// Nothing to do for in-memory database
+// ErrConflict is matched by every unique constraint violation
+var ErrConflict = errors.New("unique constraint violated")
+
+// ConflictError reports which unique field a write collided on
+type ConflictError struct {
+	Field string // "username" or "email"
 }
 
-// SaveUser saves a user to the in-memory database
-func SaveUser(user *User) error {
-	mutex.Lock()
-	defer mutex.Unlock()
-	users[user.ID] = user
-	return nil
+func (e *ConflictError) Error() string {
+	return e.Field + " is already taken"
 }
 
-// GetUserByID retrieves a user by ID
-func GetUserByID(id string) (*User, error) {
-	mutex.RLock()
-	defer mutex.RUnlock()
-	user, exists := users[id]
-	if !exists {
-		return nil, nil
-	}
-	return user, nil
+// Is makes errors.Is(err, ErrConflict) match any ConflictError
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
 }
 
-// GetUserByUsername retrieves a user by username
-func GetUserByUsername(username string) (*User, error) {
-	mutex.RLock()
-	defer mutex.RUnlock()
-	for _, user := range users {
-		if user.Username == username {
-			return user, nil
-		}
-	}
-	return nil, nil
+// NormalizeEmail returns the form of an email address used for lookups
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
-// SaveResume saves a resume to the in-memory database
-func SaveResume(resume *Resume) error {
-	mutex.Lock()
-	defer mutex.Unlock()
-	resumes[resume.ID] = resume
-	return nil
+// hashKey builds the org-scoped content hash index key
+func hashKey(orgID, hash string) string {
+	return orgID + "\x00" + hash
 }
 
-// GetResumeByID retrieves a resume by ID
-func GetResumeByID(id string) (*Resume, error) {
-	mutex.RLock()
-	defer mutex.RUnlock()
-	resume, exists := resumes[id]
-	if !exists {
-		return nil, nil
-	}
-	return resume, nil
+// oidcKey builds the index key of an external identity
+func oidcKey(issuer, subject string) string {
+	return issuer + "\x00" + subject
+}
+
+// ContentHash returns the hex SHA-256 of a resume's extracted text
+func ContentHash(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
+// Config selects and configures the storage backend
+type Config struct {
+	Driver string // DriverMemory or DriverBolt
+	Path   string // Database file, used by the bolt driver
+
+	// Snapshot file for the memory driver; empty disables snapshots
+	SnapshotPath     string
+	SnapshotInterval time.Duration
 }
 
-// GetResumesByUserID retrieves all resumes for a user
-func GetResumesByUserID(userID string) ([]*Resume, error) {
-	mutex.RLock()
-	defer mutex.RUnlock()
-	var userResumes []*Resume
-	for _, resume := range resumes {
-		if resume.UserID == userID {
-			userResumes = append(userResumes, resume)
+// current is the store opened by InitDB
+var current Store
+
+// InitDB opens the storage backend described by cfg
+func InitDB(cfg Config) (Store, error) {
+	var (
+		store Store
+		err   error
+	)
+
+	switch cfg.Driver {
+	case "", DriverMemory:
+		log.Println("Using in-memory database")
+		memory := NewMemoryStore()
+		if cfg.SnapshotPath != "" {
+			err = memory.EnableSnapshots(cfg.SnapshotPath, cfg.SnapshotInterval)
 		}
+		store = memory
+	case DriverBolt:
+		log.Printf("Using bolt database at %s", cfg.Path)
+		store, err = OpenBoltStore(cfg.Path)
+	default:
+		err = fmt.Errorf("unknown database driver %q", cfg.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Bring versioned stores up to the current schema
+	if m, ok := store.(migrator); ok {
+		if err := m.Migrate(); err != nil {
+			store.Close()
+			return nil, err
+		}
+		log.Printf("Database schema at version %d", SchemaVersion())
+	}
+
+	current = store
+	return store, nil
+}
+
+// CloseDB closes the database opened by InitDB
+func CloseDB() {
+	if current == nil {
+		return
+	}
+	if err := current.Close(); err != nil {
+		log.Println("Error closing database:", err)
 	}
-	return userResumes, nil
+	current = nil
 }
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Job is a job description resumes are matched against
+type Job struct {
+	ID               string
+	OrgID            string
+	OwnerID          string
+	Title            string
+	Description      string
+	RequiredSkills   []string
+	NiceToHaveSkills []string
+	MinYears         int
+	MinEducation     string
+	Rubric           *JobRubric // Default weights when nil
+
+	// Extracted from the description by the analyzer
+	Keywords       []string
+	DetectedSkills []string
+	Education      []string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// JobRubric weighs the components of a job's match scores
+type JobRubric struct {
+	RequiredSkills   float64
+	NiceToHaveSkills float64
+	Experience       float64
+	Education        float64
+	Keywords         float64
+}
+
+// Bucket names for jobs in the bolt store
+var jobsBucket = []byte("jobs") // job ID -> Job
+
+// SaveJob stores a job
+func (s *MemoryStore) SaveJob(job *Job) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stored := *job
+	if job.Rubric != nil {
+		rubric := *job.Rubric
+		stored.Rubric = &rubric
+	}
+	s.jobs[job.ID] = &stored
+	return nil
+}
+
+// GetJobByID retrieves a job by ID within an organization
+func (s *MemoryStore) GetJobByID(orgID, id string) (*Job, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	job, exists := s.jobs[id]
+	if !exists || job.OrgID != orgID {
+		return nil, nil
+	}
+	copied := *job
+	return &copied, nil
+}
+
+// GetJobs retrieves the jobs of an organization, or of a single owner
+// within it when ownerID is not empty
+func (s *MemoryStore) GetJobs(orgID, ownerID string) ([]*Job, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var jobs []*Job
+	for _, job := range s.jobs {
+		if job.OrgID == orgID && (ownerID == "" || job.OwnerID == ownerID) {
+			copied := *job
+			jobs = append(jobs, &copied)
+		}
+	}
+	return jobs, nil
+}
+
+// DeleteJob deletes a job within an organization
+func (s *MemoryStore) DeleteJob(orgID, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if job, exists := s.jobs[id]; exists && job.OrgID == orgID {
+		delete(s.jobs, id)
+	}
+	return nil
+}
+
+// SaveJob stores a job
+func (s *BoltStore) SaveJob(job *Job) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, jobsBucket, job.ID, job)
+	})
+}
+
+// GetJobByID retrieves a job by ID within an organization
+func (s *BoltStore) GetJobByID(orgID, id string) (*Job, error) {
+	var job Job
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		found, err = get(tx, jobsBucket, id, &job)
+		return err
+	})
+	if err != nil || !found || job.OrgID != orgID {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetJobs retrieves the jobs of an organization, or of a single owner
+// within it when ownerID is not empty
+func (s *BoltStore) GetJobs(orgID, ownerID string) ([]*Job, error) {
+	var jobs []*Job
+	err := s.db.View(func(tx *bolt.Tx) error {
+		all, err := loadAll[Job](tx, jobsBucket)
+		for _, job := range all {
+			if job.OrgID == orgID && (ownerID == "" || job.OwnerID == ownerID) {
+				jobs = append(jobs, job)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// DeleteJob deletes a job within an organization
+func (s *BoltStore) DeleteJob(orgID, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var job Job
+		found, err := get(tx, jobsBucket, id, &job)
+		if err != nil || !found || job.OrgID != orgID {
+			return err
+		}
+		return tx.Bucket(jobsBucket).Delete([]byte(id))
+	})
+}
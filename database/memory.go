@@ -0,0 +1,350 @@
+package database
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps all records in process memory.
+// Everything is lost when the process exits unless snapshots are enabled.
+type MemoryStore struct {
+	mutex   sync.RWMutex
+	users   map[string]*User
+	resumes map[string]*Resume
+
+	// Secondary indexes, maintained under mutex by every write
+	usersByUsername map[string]string              // username -> user ID
+	usersByEmail    map[string]string              // normalized email -> user ID
+	usersByOrg      map[string]map[string]struct{} // org ID -> user IDs
+	usersByOIDC     map[string]string              // issuer \x00 subject -> user ID
+	resumesByUser   map[string]map[string]struct{} // user ID -> resume IDs
+	resumesByOrg    map[string]map[string]struct{} // org ID -> resume IDs
+	resumesByHash   map[string]string              // org ID \x00 content hash -> resume ID
+
+	// Organizations, see orgs.go
+	organizations map[string]*Organization
+	invitations   map[string]*Invitation // token hash -> invitation
+
+	// API keys, see apikeys.go
+	apiKeys       map[string]*APIKey
+	apiKeysByHash map[string]string // key hash -> key ID
+
+	// Jobs, see jobs.go
+	jobs map[string]*Job
+
+	// Audit trail in Seq order, see audit.go
+	auditEvents []*AuditEvent
+
+	// Token state, see tokens.go
+	refreshTokens map[string]*RefreshToken // hash -> token
+	revokedTokens map[string]time.Time     // access token jti -> expiry
+
+	// Periodic snapshotting, see EnableSnapshots
+	snapshotPath  string
+	stopSnapshots chan struct{}
+	snapshotsDone chan struct{}
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{}
+	s.reset(&snapshot{})
+	return s
+}
+
+// reset replaces all records with the snapshot contents and rebuilds the
+// indexes. Callers hold the lock.
+func (s *MemoryStore) reset(snap *snapshot) {
+	users, resumes := snap.Users, snap.Resumes
+	s.users = make(map[string]*User, len(users))
+	s.resumes = make(map[string]*Resume, len(resumes))
+	s.usersByUsername = make(map[string]string, len(users))
+	s.usersByEmail = make(map[string]string, len(users))
+	s.usersByOrg = make(map[string]map[string]struct{})
+	s.usersByOIDC = make(map[string]string)
+	s.resumesByUser = make(map[string]map[string]struct{})
+	s.resumesByOrg = make(map[string]map[string]struct{})
+	s.resumesByHash = make(map[string]string, len(resumes))
+
+	for _, user := range users {
+		s.indexUser(user)
+	}
+	for _, resume := range resumes {
+		s.indexResume(resume)
+	}
+
+	s.organizations = make(map[string]*Organization, len(snap.Organizations))
+	for _, org := range snap.Organizations {
+		s.organizations[org.ID] = org
+	}
+	s.invitations = make(map[string]*Invitation, len(snap.Invitations))
+	for _, invitation := range snap.Invitations {
+		s.invitations[invitation.Hash] = invitation
+	}
+
+	s.apiKeys = make(map[string]*APIKey, len(snap.APIKeys))
+	s.apiKeysByHash = make(map[string]string, len(snap.APIKeys))
+	for _, key := range snap.APIKeys {
+		s.apiKeys[key.ID] = key
+		s.apiKeysByHash[key.Hash] = key.ID
+	}
+
+	s.jobs = make(map[string]*Job, len(snap.Jobs))
+	for _, job := range snap.Jobs {
+		s.jobs[job.ID] = job
+	}
+
+	s.auditEvents = append([]*AuditEvent(nil), snap.AuditEvents...)
+
+	s.refreshTokens = make(map[string]*RefreshToken, len(snap.RefreshTokens))
+	for _, token := range snap.RefreshTokens {
+		s.refreshTokens[token.Hash] = token
+	}
+	s.revokedTokens = make(map[string]time.Time, len(snap.RevokedTokens))
+	for jti, expiry := range snap.RevokedTokens {
+		s.revokedTokens[jti] = expiry
+	}
+}
+
+// Close flushes a final snapshot when snapshotting is enabled
+func (s *MemoryStore) Close() error {
+	if s.stopSnapshots == nil {
+		return nil
+	}
+	close(s.stopSnapshots)
+	<-s.snapshotsDone
+	s.stopSnapshots = nil
+	return s.SaveSnapshot(s.snapshotPath)
+}
+
+// addToSet adds id to the set stored under key in a one-to-many index
+func addToSet(index map[string]map[string]struct{}, key, id string) {
+	ids, exists := index[key]
+	if !exists {
+		ids = make(map[string]struct{})
+		index[key] = ids
+	}
+	ids[id] = struct{}{}
+}
+
+// removeFromSet removes id from the set stored under key
+func removeFromSet(index map[string]map[string]struct{}, key, id string) {
+	if ids, exists := index[key]; exists {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(index, key)
+		}
+	}
+}
+
+// copyUser returns a copy of a stored user so callers can modify it
+// without touching the store's indexes behind its back
+func copyUser(user *User) *User {
+	if user == nil {
+		return nil
+	}
+	copied := *user
+	return &copied
+}
+
+// copyResume returns a copy of a stored resume, see copyUser
+func copyResume(resume *Resume) *Resume {
+	if resume == nil {
+		return nil
+	}
+	copied := *resume
+	return &copied
+}
+
+// indexUser stores a user and adds it to the indexes
+func (s *MemoryStore) indexUser(user *User) {
+	s.users[user.ID] = user
+	s.usersByUsername[user.Username] = user.ID
+	addToSet(s.usersByOrg, user.OrgID, user.ID)
+	if user.Email != "" {
+		s.usersByEmail[NormalizeEmail(user.Email)] = user.ID
+	}
+	if user.OIDCSubject != "" {
+		s.usersByOIDC[oidcKey(user.OIDCIssuer, user.OIDCSubject)] = user.ID
+	}
+}
+
+// unindexUser removes a user and its index entries
+func (s *MemoryStore) unindexUser(user *User) {
+	delete(s.users, user.ID)
+	removeFromSet(s.usersByOrg, user.OrgID, user.ID)
+	if s.usersByUsername[user.Username] == user.ID {
+		delete(s.usersByUsername, user.Username)
+	}
+	email := NormalizeEmail(user.Email)
+	if s.usersByEmail[email] == user.ID {
+		delete(s.usersByEmail, email)
+	}
+	if key := oidcKey(user.OIDCIssuer, user.OIDCSubject); s.usersByOIDC[key] == user.ID {
+		delete(s.usersByOIDC, key)
+	}
+}
+
+// indexResume stores a resume and adds it to the indexes
+func (s *MemoryStore) indexResume(resume *Resume) {
+	s.resumes[resume.ID] = resume
+	addToSet(s.resumesByUser, resume.UserID, resume.ID)
+	addToSet(s.resumesByOrg, resume.OrgID, resume.ID)
+	if resume.ContentHash != "" {
+		s.resumesByHash[hashKey(resume.OrgID, resume.ContentHash)] = resume.ID
+	}
+}
+
+// unindexResume removes a resume and its index entries
+func (s *MemoryStore) unindexResume(resume *Resume) {
+	delete(s.resumes, resume.ID)
+	removeFromSet(s.resumesByUser, resume.UserID, resume.ID)
+	removeFromSet(s.resumesByOrg, resume.OrgID, resume.ID)
+	if key := hashKey(resume.OrgID, resume.ContentHash); s.resumesByHash[key] == resume.ID {
+		delete(s.resumesByHash, key)
+	}
+}
+
+// SaveUser saves a user to the in-memory database.
+// It returns a *ConflictError if the username or email belongs to another user.
+func (s *MemoryStore) SaveUser(user *User) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if id, exists := s.usersByUsername[user.Username]; exists && id != user.ID {
+		return &ConflictError{Field: "username"}
+	}
+	if user.Email != "" {
+		if id, exists := s.usersByEmail[NormalizeEmail(user.Email)]; exists && id != user.ID {
+			return &ConflictError{Field: "email"}
+		}
+	}
+	if user.OIDCSubject != "" {
+		if id, exists := s.usersByOIDC[oidcKey(user.OIDCIssuer, user.OIDCSubject)]; exists && id != user.ID {
+			return &ConflictError{Field: "oidc_subject"}
+		}
+	}
+	if old, exists := s.users[user.ID]; exists {
+		s.unindexUser(old)
+	}
+	stored := *user
+	s.indexUser(&stored)
+	return nil
+}
+
+// GetUserByID retrieves a user by ID
+func (s *MemoryStore) GetUserByID(id string) (*User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return copyUser(s.users[id]), nil
+}
+
+// GetUserByUsername retrieves a user by username
+func (s *MemoryStore) GetUserByUsername(username string) (*User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return copyUser(s.users[s.usersByUsername[username]]), nil
+}
+
+// GetUserByEmail retrieves a user by email, ignoring case
+func (s *MemoryStore) GetUserByEmail(email string) (*User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return copyUser(s.users[s.usersByEmail[NormalizeEmail(email)]]), nil
+}
+
+// GetUserByOIDCSubject retrieves the user linked to an external identity
+func (s *MemoryStore) GetUserByOIDCSubject(issuer, subject string) (*User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return copyUser(s.users[s.usersByOIDC[oidcKey(issuer, subject)]]), nil
+}
+
+// GetUsersByOrgID retrieves all users in an organization
+func (s *MemoryStore) GetUsersByOrgID(orgID string) ([]*User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var orgUsers []*User
+	for id := range s.usersByOrg[orgID] {
+		orgUsers = append(orgUsers, copyUser(s.users[id]))
+	}
+	return orgUsers, nil
+}
+
+// DeleteUser removes a user. Deleting a missing user is not an error.
+func (s *MemoryStore) DeleteUser(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if user, exists := s.users[id]; exists {
+		s.unindexUser(user)
+	}
+	return nil
+}
+
+// SaveResume saves a resume to the in-memory database
+func (s *MemoryStore) SaveResume(resume *Resume) error {
+	if resume.ContentHash == "" {
+		resume.ContentHash = ContentHash(resume.Content)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if old, exists := s.resumes[resume.ID]; exists {
+		s.unindexResume(old)
+	}
+	stored := *resume
+	s.indexResume(&stored)
+	return nil
+}
+
+// GetResumeByID retrieves a resume by ID within an organization
+func (s *MemoryStore) GetResumeByID(orgID, id string) (*Resume, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	resume, exists := s.resumes[id]
+	if !exists || resume.OrgID != orgID {
+		return nil, nil
+	}
+	return copyResume(resume), nil
+}
+
+// GetResumeByContentHash retrieves a resume with the given content hash
+// within an organization
+func (s *MemoryStore) GetResumeByContentHash(orgID, hash string) (*Resume, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return copyResume(s.resumes[s.resumesByHash[hashKey(orgID, hash)]]), nil
+}
+
+// GetResumesByUserID retrieves all resumes for a user within an organization
+func (s *MemoryStore) GetResumesByUserID(orgID, userID string) ([]*Resume, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var userResumes []*Resume
+	for id := range s.resumesByUser[userID] {
+		if resume := s.resumes[id]; resume.OrgID == orgID {
+			userResumes = append(userResumes, copyResume(resume))
+		}
+	}
+	return userResumes, nil
+}
+
+// GetResumesByOrgID retrieves all resumes owned by an organization
+func (s *MemoryStore) GetResumesByOrgID(orgID string) ([]*Resume, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var orgResumes []*Resume
+	for id := range s.resumesByOrg[orgID] {
+		orgResumes = append(orgResumes, copyResume(s.resumes[id]))
+	}
+	return orgResumes, nil
+}
+
+// DeleteResume removes a resume within an organization.
+// Deleting a missing resume is not an error.
+func (s *MemoryStore) DeleteResume(orgID, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if resume, exists := s.resumes[id]; exists && resume.OrgID == orgID {
+		s.unindexResume(resume)
+	}
+	return nil
+}
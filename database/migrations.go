@@ -0,0 +1,293 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bookkeeping buckets for schema versioning
+var (
+	metaBucket       = []byte("meta")
+	migrationsBucket = []byte("migrations")
+	schemaVersionKey = []byte("schema_version")
+)
+
+// migration is a single ordered step of the on-disk schema.
+// Steps must be idempotent so a partially applied history can be re-run.
+type migration struct {
+	Version int
+	Name    string
+	Apply   func(tx *bolt.Tx) error
+}
+
+// migrationRecord is stored for every applied migration
+type migrationRecord struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// migrations lists every schema change in order. Append new steps at the end
+// and never edit or reorder a released one.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "create users and resumes buckets",
+		Apply: func(tx *bolt.Tx) error {
+			return createBuckets(tx, usersBucket, resumesBucket)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "add secondary indexes and content hashes",
+		Apply: func(tx *bolt.Tx) error {
+			err := createBuckets(tx, usersByUsernameBucket, usersByEmailBucket,
+				resumesByUserBucket, resumesByHashBucket)
+			if err != nil {
+				return err
+			}
+
+			// Backfill the new indexes from existing records. Only the buckets
+			// created above exist yet, so indexUser and indexResume cannot be
+			// used here; later migrations add the rest.
+			users, err := loadAll[User](tx, usersBucket)
+			if err != nil {
+				return err
+			}
+			for _, user := range users {
+				if err := tx.Bucket(usersByUsernameBucket).Put([]byte(user.Username), []byte(user.ID)); err != nil {
+					return err
+				}
+				if user.Email == "" {
+					continue
+				}
+				if err := tx.Bucket(usersByEmailBucket).Put([]byte(NormalizeEmail(user.Email)), []byte(user.ID)); err != nil {
+					return err
+				}
+			}
+			resumes, err := loadAll[Resume](tx, resumesBucket)
+			if err != nil {
+				return err
+			}
+			for _, resume := range resumes {
+				if resume.ContentHash == "" {
+					resume.ContentHash = ContentHash(resume.Content)
+					if err := put(tx, resumesBucket, resume.ID, resume); err != nil {
+						return err
+					}
+				}
+				if err := tx.Bucket(resumesByUserBucket).Put(setKey(resume.UserID, resume.ID), nil); err != nil {
+					return err
+				}
+				if err := tx.Bucket(resumesByHashBucket).Put([]byte(hashKey(resume.OrgID, resume.ContentHash)), []byte(resume.ID)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 3,
+		Name:    "add refresh token and access token denylist buckets",
+		Apply: func(tx *bolt.Tx) error {
+			return createBuckets(tx, refreshTokensBucket, revokedTokensBucket)
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add roles and organization indexes",
+		Apply: func(tx *bolt.Tx) error {
+			if err := createBuckets(tx, usersByOrgBucket, resumesByOrgBucket); err != nil {
+				return err
+			}
+
+			users, err := loadAll[User](tx, usersBucket)
+			if err != nil {
+				return err
+			}
+			for _, user := range users {
+				// Accounts created before roles existed are candidates
+				if user.Role == "" {
+					user.Role = "candidate"
+					if err := put(tx, usersBucket, user.ID, user); err != nil {
+						return err
+					}
+				}
+				if err := indexUser(tx, user); err != nil {
+					return err
+				}
+			}
+
+			resumes, err := loadAll[Resume](tx, resumesBucket)
+			if err != nil {
+				return err
+			}
+			for _, resume := range resumes {
+				if err := indexResume(tx, resume); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add organizations and scope content hashes by organization",
+		Apply: func(tx *bolt.Tx) error {
+			if err := createBuckets(tx, organizationsBucket, invitationsBucket); err != nil {
+				return err
+			}
+
+			// Content hash keys now carry the org ID, so rebuild that index
+			if err := tx.DeleteBucket(resumesByHashBucket); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+			if err := createBuckets(tx, resumesByHashBucket); err != nil {
+				return err
+			}
+			resumes, err := loadAll[Resume](tx, resumesBucket)
+			if err != nil {
+				return err
+			}
+			for _, resume := range resumes {
+				if err := indexResume(tx, resume); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 6,
+		Name:    "add API key buckets",
+		Apply: func(tx *bolt.Tx) error {
+			return createBuckets(tx, apiKeysBucket, apiKeysByHashBucket)
+		},
+	},
+	{
+		Version: 7,
+		Name:    "add OpenID Connect identity index",
+		Apply: func(tx *bolt.Tx) error {
+			return createBuckets(tx, usersByOIDCBucket)
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add audit event bucket",
+		Apply: func(tx *bolt.Tx) error {
+			return createBuckets(tx, auditEventsBucket)
+		},
+	},
+	{
+		Version: 9,
+		Name:    "add job bucket",
+		Apply: func(tx *bolt.Tx) error {
+			return createBuckets(tx, jobsBucket)
+		},
+	},
+}
+
+// SchemaVersion is the newest schema this build understands
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// migrator is implemented by stores that carry a versioned on-disk schema
+type migrator interface {
+	Migrate() error
+}
+
+// createBuckets creates every named bucket that does not exist yet
+func createBuckets(tx *bolt.Tx, names ...[]byte) error {
+	for _, name := range names {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAll decodes every record in a bucket. Migrations use it to collect
+// records before rewriting them, since bolt forbids writes during ForEach.
+func loadAll[T any](tx *bolt.Tx, bucket []byte) ([]*T, error) {
+	var records []*T
+	err := tx.Bucket(bucket).ForEach(func(k, v []byte) error {
+		record := new(T)
+		if err := json.Unmarshal(v, record); err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+// readSchemaVersion returns the recorded schema version, 0 for a fresh file
+func readSchemaVersion(tx *bolt.Tx) int {
+	meta := tx.Bucket(metaBucket)
+	if meta == nil {
+		return 0
+	}
+	data := meta.Get(schemaVersionKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(data))
+}
+
+// Migrate brings the bolt file up to SchemaVersion.
+// All pending migrations run in a single transaction, so a failure leaves
+// the file at its previous version.
+func (s *BoltStore) Migrate() error {
+	// Sanity check the migration list itself
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return fmt.Errorf("migration %q has version %d, expected %d", m.Name, m.Version, i+1)
+		}
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		current := readSchemaVersion(tx)
+		if current > SchemaVersion() {
+			return fmt.Errorf("database schema version %d is newer than supported version %d", current, SchemaVersion())
+		}
+		if current == SchemaVersion() {
+			return nil
+		}
+
+		if err := createBuckets(tx, metaBucket, migrationsBucket); err != nil {
+			return err
+		}
+
+		for _, m := range migrations[current:] {
+			log.Printf("Applying migration %d: %s", m.Version, m.Name)
+			if err := m.Apply(tx); err != nil {
+				return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+			}
+
+			// Record the migration
+			record, err := json.Marshal(migrationRecord{
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, uint64(m.Version))
+			if err := tx.Bucket(migrationsBucket).Put(key, record); err != nil {
+				return err
+			}
+			if err := tx.Bucket(metaBucket).Put(schemaVersionKey, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
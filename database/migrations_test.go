@@ -0,0 +1,119 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// writeV1File creates a bolt file as the first schema left it: users and
+// resumes buckets only, with one user owning one resume
+func writeV1File(t *testing.T, path string) {
+	t.Helper()
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := createBuckets(tx, metaBucket, usersBucket, resumesBucket); err != nil {
+			return err
+		}
+		version := make([]byte, 8)
+		binary.BigEndian.PutUint64(version, 1)
+		if err := tx.Bucket(metaBucket).Put(schemaVersionKey, version); err != nil {
+			return err
+		}
+
+		// Records as v1 stored them, without roles, orgs or content hashes
+		user, err := json.Marshal(map[string]interface{}{
+			"ID":        "user-1",
+			"Username":  "alice",
+			"Password":  "hash",
+			"Email":     "Alice@Example.com",
+			"CreatedAt": time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(usersBucket).Put([]byte("user-1"), user); err != nil {
+			return err
+		}
+		resume, err := json.Marshal(map[string]interface{}{
+			"ID":         "resume-1",
+			"UserID":     "user-1",
+			"Filename":   "resume.pdf",
+			"Content":    "go developer",
+			"UploadedAt": time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(resumesBucket).Put([]byte("resume-1"), resume)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateFromV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v1.db")
+	writeV1File(t, path)
+
+	store, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	var version int
+	store.db.View(func(tx *bolt.Tx) error {
+		version = readSchemaVersion(tx)
+		return nil
+	})
+	if version != SchemaVersion() {
+		t.Fatalf("schema version = %d, want %d", version, SchemaVersion())
+	}
+
+	// Every index is backfilled
+	user, err := store.GetUserByUsername("alice")
+	if err != nil || user == nil {
+		t.Fatalf("GetUserByUsername = %v, %v", user, err)
+	}
+	if user.Role != "candidate" {
+		t.Errorf("role = %q, want candidate", user.Role)
+	}
+	if byEmail, err := store.GetUserByEmail("alice@example.com"); err != nil || byEmail == nil || byEmail.ID != user.ID {
+		t.Errorf("GetUserByEmail = %v, %v", byEmail, err)
+	}
+	if members, err := store.GetUsersByOrgID(""); err != nil || len(members) != 1 {
+		t.Errorf("GetUsersByOrgID = %v, %v", members, err)
+	}
+
+	resumes, err := store.GetResumesByUserID("", user.ID)
+	if err != nil || len(resumes) != 1 {
+		t.Fatalf("GetResumesByUserID = %v, %v", resumes, err)
+	}
+	if resumes[0].ContentHash != ContentHash("go developer") {
+		t.Errorf("content hash = %q", resumes[0].ContentHash)
+	}
+	if byHash, err := store.GetResumeByContentHash("", resumes[0].ContentHash); err != nil || byHash == nil {
+		t.Errorf("GetResumeByContentHash = %v, %v", byHash, err)
+	}
+	if inOrg, err := store.GetResumesByOrgID(""); err != nil || len(inOrg) != 1 {
+		t.Errorf("GetResumesByOrgID = %v, %v", inOrg, err)
+	}
+
+	// Migrating again is a no-op
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+}
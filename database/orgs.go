@@ -0,0 +1,193 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// OrgSettings are per-organization options consulted by the analyzer and handlers
+type OrgSettings struct {
+	SkillDictionary []string // Extra skills recognized on top of the built-in list
+	RetentionDays   int      // Resumes older than this are purged; 0 keeps them forever
+	RequireMFA      bool     // Members must use a second factor to log in
+}
+
+// Organization is a tenant that owns users and resumes
+type Organization struct {
+	ID        string
+	Name      string
+	Settings  OrgSettings
+	CreatedAt time.Time
+}
+
+// Invitation lets the holder of an emailed token join an organization.
+// Only the SHA-256 hash of the token is stored.
+type Invitation struct {
+	Hash      string
+	OrgID     string
+	Email     string
+	Role      string
+	InvitedBy string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Accepted  bool
+}
+
+// Bucket names for organizations in the bolt store
+var (
+	organizationsBucket = []byte("organizations") // org ID -> Organization
+	invitationsBucket   = []byte("invitations")   // token hash -> Invitation
+)
+
+// SaveOrganization saves an organization to the in-memory database
+func (s *MemoryStore) SaveOrganization(org *Organization) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stored := *org
+	s.organizations[org.ID] = &stored
+	return nil
+}
+
+// GetOrganizationByID retrieves an organization by ID
+func (s *MemoryStore) GetOrganizationByID(id string) (*Organization, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	org, exists := s.organizations[id]
+	if !exists {
+		return nil, nil
+	}
+	copied := *org
+	return &copied, nil
+}
+
+// GetOrganizations retrieves every organization
+func (s *MemoryStore) GetOrganizations() ([]*Organization, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var orgs []*Organization
+	for _, org := range s.organizations {
+		copied := *org
+		orgs = append(orgs, &copied)
+	}
+	return orgs, nil
+}
+
+// SaveInvitation stores an invitation
+func (s *MemoryStore) SaveInvitation(invitation *Invitation) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.invitations[invitation.Hash] = invitation
+	return nil
+}
+
+// GetInvitation retrieves an invitation by token hash
+func (s *MemoryStore) GetInvitation(hash string) (*Invitation, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	invitation, exists := s.invitations[hash]
+	if !exists {
+		return nil, nil
+	}
+	copied := *invitation
+	return &copied, nil
+}
+
+// UseInvitation marks an invitation as accepted and returns its state from
+// before the call, or nil if no such invitation exists
+func (s *MemoryStore) UseInvitation(hash string) (*Invitation, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	invitation, exists := s.invitations[hash]
+	if !exists {
+		return nil, nil
+	}
+	prior := *invitation
+	invitation.Accepted = true
+	return &prior, nil
+}
+
+// SaveOrganization saves an organization to the bolt database
+func (s *BoltStore) SaveOrganization(org *Organization) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, organizationsBucket, org.ID, org)
+	})
+}
+
+// GetOrganizationByID retrieves an organization by ID
+func (s *BoltStore) GetOrganizationByID(id string) (*Organization, error) {
+	var org Organization
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		found, err = get(tx, organizationsBucket, id, &org)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// GetOrganizations retrieves every organization
+func (s *BoltStore) GetOrganizations() ([]*Organization, error) {
+	var orgs []*Organization
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(organizationsBucket).ForEach(func(k, v []byte) error {
+			var org Organization
+			if err := json.Unmarshal(v, &org); err != nil {
+				return err
+			}
+			orgs = append(orgs, &org)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// SaveInvitation stores an invitation
+func (s *BoltStore) SaveInvitation(invitation *Invitation) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, invitationsBucket, invitation.Hash, invitation)
+	})
+}
+
+// GetInvitation retrieves an invitation by token hash
+func (s *BoltStore) GetInvitation(hash string) (*Invitation, error) {
+	var invitation Invitation
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		found, err = get(tx, invitationsBucket, hash, &invitation)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// UseInvitation marks an invitation as accepted and returns its state from
+// before the call, or nil if no such invitation exists
+func (s *BoltStore) UseInvitation(hash string) (*Invitation, error) {
+	var prior *Invitation
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var invitation Invitation
+		found, err := get(tx, invitationsBucket, hash, &invitation)
+		if err != nil || !found {
+			return err
+		}
+		saved := invitation
+		prior = &saved
+		invitation.Accepted = true
+		return put(tx, invitationsBucket, hash, &invitation)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prior, nil
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotFormatVersion is bumped whenever the snapshot layout changes
+const snapshotFormatVersion = 1
+
+// snapshot is the on-disk JSON representation of a MemoryStore
+type snapshot struct {
+	Version int       `json:"version"`
+	TakenAt time.Time `json:"taken_at"`
+	Users   []*User   `json:"users"`
+	Resumes []*Resume `json:"resumes"`
+
+	Organizations []*Organization `json:"organizations,omitempty"`
+	Invitations   []*Invitation   `json:"invitations,omitempty"`
+	APIKeys       []*APIKey       `json:"api_keys,omitempty"`
+	Jobs          []*Job          `json:"jobs,omitempty"`
+	AuditEvents   []*AuditEvent   `json:"audit_events,omitempty"`
+
+	RefreshTokens []*RefreshToken      `json:"refresh_tokens,omitempty"`
+	RevokedTokens map[string]time.Time `json:"revoked_tokens,omitempty"`
+}
+
+// Snapshotter is implemented by stores that can dump and reload their
+// contents as a single file
+type Snapshotter interface {
+	SaveSnapshot(path string) error
+	LoadSnapshot(path string) error
+	SnapshotPath() string
+}
+
+// WriteSnapshot writes the full store contents to w as JSON
+func (s *MemoryStore) WriteSnapshot(w io.Writer) error {
+	// Hold the read lock until encoded so records are not mutated mid-write
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	snap := snapshot{
+		Version: snapshotFormatVersion,
+		TakenAt: time.Now(),
+		Users:   make([]*User, 0, len(s.users)),
+		Resumes: make([]*Resume, 0, len(s.resumes)),
+
+		Organizations: make([]*Organization, 0, len(s.organizations)),
+		Invitations:   make([]*Invitation, 0, len(s.invitations)),
+		APIKeys:       make([]*APIKey, 0, len(s.apiKeys)),
+		Jobs:          make([]*Job, 0, len(s.jobs)),
+		AuditEvents:   s.auditEvents,
+
+		RefreshTokens: make([]*RefreshToken, 0, len(s.refreshTokens)),
+		RevokedTokens: s.revokedTokens,
+	}
+	for _, user := range s.users {
+		snap.Users = append(snap.Users, user)
+	}
+	for _, resume := range s.resumes {
+		snap.Resumes = append(snap.Resumes, resume)
+	}
+	for _, org := range s.organizations {
+		snap.Organizations = append(snap.Organizations, org)
+	}
+	for _, invitation := range s.invitations {
+		snap.Invitations = append(snap.Invitations, invitation)
+	}
+	for _, key := range s.apiKeys {
+		snap.APIKeys = append(snap.APIKeys, key)
+	}
+	for _, job := range s.jobs {
+		snap.Jobs = append(snap.Jobs, job)
+	}
+	for _, token := range s.refreshTokens {
+		snap.RefreshTokens = append(snap.RefreshTokens, token)
+	}
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// ReadSnapshot replaces the store contents with the snapshot read from r
+func (s *MemoryStore) ReadSnapshot(r io.Reader) error {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+	if snap.Version > snapshotFormatVersion {
+		return fmt.Errorf("snapshot format version %d is newer than supported version %d", snap.Version, snapshotFormatVersion)
+	}
+
+	// Older snapshots predate content hashes
+	for _, resume := range snap.Resumes {
+		if resume.ContentHash == "" {
+			resume.ContentHash = ContentHash(resume.Content)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.reset(&snap)
+	return nil
+}
+
+// SaveSnapshot atomically writes a snapshot to path.
+// The data goes to a temporary file in the same directory which is synced
+// and then renamed over path, so readers never see a partial snapshot.
+func (s *MemoryStore) SaveSnapshot(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if err := s.WriteSnapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot replaces the store contents with the snapshot at path
+func (s *MemoryStore) LoadSnapshot(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.ReadSnapshot(f)
+}
+
+// SnapshotPath returns the file used for periodic snapshots, if any
+func (s *MemoryStore) SnapshotPath() string {
+	return s.snapshotPath
+}
+
+// EnableSnapshots reloads the store from path when it exists and then
+// writes a fresh snapshot every interval until Close is called.
+// An interval of zero only snapshots on Close.
+func (s *MemoryStore) EnableSnapshots(path string, interval time.Duration) error {
+	if err := s.LoadSnapshot(path); err != nil && !os.IsNotExist(err) {
+		return err
+	} else if err == nil {
+		log.Printf("Restored in-memory database from %s", path)
+	}
+
+	s.snapshotPath = path
+	s.stopSnapshots = make(chan struct{})
+	s.snapshotsDone = make(chan struct{})
+
+	go func() {
+		defer close(s.snapshotsDone)
+		if interval <= 0 {
+			<-s.stopSnapshots
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.SaveSnapshot(path); err != nil {
+					log.Println("Error writing snapshot:", err)
+				}
+			case <-s.stopSnapshots:
+				return
+			}
+		}
+	}()
+	return nil
+}
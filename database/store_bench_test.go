@@ -0,0 +1,144 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// benchSizes are the store sizes lookups are measured at. Indexed lookups
+// should cost about the same at every size.
+var benchSizes = []int{1e2, 1e4, 1e5}
+
+// benchUser and benchResume build the i-th seeded record; every user owns
+// one resume
+func benchUser(i int) *User {
+	return &User{
+		ID:        fmt.Sprintf("user-%d", i),
+		Username:  fmt.Sprintf("user%d", i),
+		Email:     fmt.Sprintf("user%d@example.com", i),
+		Role:      "candidate",
+		CreatedAt: time.Now(),
+	}
+}
+
+func benchResume(i int) *Resume {
+	content := fmt.Sprintf("resume %d", i)
+	return &Resume{
+		ID:          fmt.Sprintf("resume-%d", i),
+		UserID:      fmt.Sprintf("user-%d", i),
+		Filename:    "resume.pdf",
+		Content:     content,
+		ContentHash: ContentHash(content),
+		UploadedAt:  time.Now(),
+	}
+}
+
+// seedMemory returns a memory store holding n users and their resumes
+func seedMemory(b *testing.B, n int) Store {
+	store := NewMemoryStore()
+	for i := 0; i < n; i++ {
+		if err := store.SaveUser(benchUser(i)); err != nil {
+			b.Fatal(err)
+		}
+		if err := store.SaveResume(benchResume(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return store
+}
+
+// seedBatch is how many records seedBolt writes per transaction
+const seedBatch = 1000
+
+// seedBolt returns a bolt store holding n users and their resumes. The
+// records are written in batches, since a commit per record would make
+// seeding the large sizes take minutes.
+func seedBolt(b *testing.B, n int) Store {
+	store, err := OpenBoltStore(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := store.Migrate(); err != nil {
+		b.Fatal(err)
+	}
+	for start := 0; start < n; start += seedBatch {
+		if err := seedBoltBatch(store, start, min(start+seedBatch, n)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return store
+}
+
+// seedBoltBatch writes records start to end-1 in one transaction
+func seedBoltBatch(store *BoltStore, start, end int) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		for i := start; i < end; i++ {
+			user, resume := benchUser(i), benchResume(i)
+			if err := put(tx, usersBucket, user.ID, user); err != nil {
+				return err
+			}
+			if err := indexUser(tx, user); err != nil {
+				return err
+			}
+			if err := put(tx, resumesBucket, resume.ID, resume); err != nil {
+				return err
+			}
+			if err := indexResume(tx, resume); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// benchStores runs bench against every store at every size. Each store is
+// seeded once, outside b.Run, which calls its function several times.
+func benchStores(b *testing.B, bench func(b *testing.B, store Store, n int)) {
+	// Migration progress would drown the results
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for _, s := range []struct {
+		name string
+		seed func(b *testing.B, n int) Store
+	}{{"memory", seedMemory}, {"bolt", seedBolt}} {
+		for _, n := range benchSizes {
+			store := s.seed(b, n)
+			b.Run(fmt.Sprintf("%s/n=%d", s.name, n), func(b *testing.B) {
+				bench(b, store, n)
+			})
+			store.Close()
+		}
+	}
+}
+
+func BenchmarkGetUserByUsername(b *testing.B) {
+	benchStores(b, func(b *testing.B, store Store, n int) {
+		for i := 0; i < b.N; i++ {
+			username := fmt.Sprintf("user%d", i%n)
+			user, err := store.GetUserByUsername(username)
+			if err != nil || user == nil {
+				b.Fatalf("GetUserByUsername(%s) = %v, %v", username, user, err)
+			}
+		}
+	})
+}
+
+func BenchmarkGetResumesByUserID(b *testing.B) {
+	benchStores(b, func(b *testing.B, store Store, n int) {
+		for i := 0; i < b.N; i++ {
+			userID := fmt.Sprintf("user-%d", i%n)
+			resumes, err := store.GetResumesByUserID("", userID)
+			if err != nil || len(resumes) != 1 {
+				b.Fatalf("GetResumesByUserID(%s) = %d resumes, %v", userID, len(resumes), err)
+			}
+		}
+	})
+}
@@ -0,0 +1,335 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// stores lists every Store implementation the contract tests run against
+var stores = []struct {
+	name string
+	open func(t *testing.T) Store
+}{
+	{"memory", func(t *testing.T) Store {
+		return NewMemoryStore()
+	}},
+	{"bolt", func(t *testing.T) Store {
+		store, err := OpenBoltStore(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Migrate(); err != nil {
+			store.Close()
+			t.Fatal(err)
+		}
+		return store
+	}},
+}
+
+// forEachStore runs test against a fresh instance of every store
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.open(t)
+			t.Cleanup(func() { store.Close() })
+			test(t, store)
+		})
+	}
+}
+
+// mustSaveUser saves a user or fails the test
+func mustSaveUser(t *testing.T, store Store, user *User) {
+	t.Helper()
+	if err := store.SaveUser(user); err != nil {
+		t.Fatalf("SaveUser(%s): %v", user.ID, err)
+	}
+}
+
+// mustSaveResume saves a resume or fails the test
+func mustSaveResume(t *testing.T, store Store, resume *Resume) {
+	t.Helper()
+	if err := store.SaveResume(resume); err != nil {
+		t.Fatalf("SaveResume(%s): %v", resume.ID, err)
+	}
+}
+
+// resumeIDs returns the sorted IDs of resumes
+func resumeIDs(resumes []*Resume) []string {
+	ids := []string{}
+	for _, resume := range resumes {
+		ids = append(ids, resume.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// equalIDs reports whether two sorted ID lists match
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStoreUserLookups(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		mustSaveUser(t, store, &User{ID: "u1", Username: "alice", Email: "Alice@Example.com", OrgID: "org1"})
+		mustSaveUser(t, store, &User{ID: "u2", Username: "bobby", OrgID: "org1"})
+
+		if user, err := store.GetUserByID("u1"); err != nil || user == nil || user.Username != "alice" {
+			t.Errorf("GetUserByID = %v, %v", user, err)
+		}
+		if user, err := store.GetUserByUsername("alice"); err != nil || user == nil || user.ID != "u1" {
+			t.Errorf("GetUserByUsername = %v, %v", user, err)
+		}
+		if user, err := store.GetUserByEmail(" alice@EXAMPLE.com"); err != nil || user == nil || user.ID != "u1" {
+			t.Errorf("GetUserByEmail ignoring case = %v, %v", user, err)
+		}
+		if users, err := store.GetUsersByOrgID("org1"); err != nil || len(users) != 2 {
+			t.Errorf("GetUsersByOrgID = %d users, %v", len(users), err)
+		}
+
+		// Lookups return nil, nil when nothing matches
+		if user, err := store.GetUserByID("missing"); err != nil || user != nil {
+			t.Errorf("GetUserByID(missing) = %v, %v", user, err)
+		}
+		if user, err := store.GetUserByUsername("missing"); err != nil || user != nil {
+			t.Errorf("GetUserByUsername(missing) = %v, %v", user, err)
+		}
+		if user, err := store.GetUserByEmail(""); err != nil || user != nil {
+			t.Errorf("GetUserByEmail(empty) = %v, %v", user, err)
+		}
+		if users, err := store.GetUsersByOrgID("other"); err != nil || len(users) != 0 {
+			t.Errorf("GetUsersByOrgID(other) = %v, %v", users, err)
+		}
+	})
+}
+
+func TestStoreUserConflicts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		mustSaveUser(t, store, &User{ID: "u1", Username: "alice", Email: "alice@example.com",
+			OIDCIssuer: "https://idp", OIDCSubject: "sub-1"})
+
+		tests := []struct {
+			user  *User
+			field string
+		}{
+			{&User{ID: "u2", Username: "alice"}, "username"},
+			{&User{ID: "u2", Username: "bobby", Email: "ALICE@example.com"}, "email"},
+			{&User{ID: "u2", Username: "bobby", OIDCIssuer: "https://idp", OIDCSubject: "sub-1"}, "oidc_subject"},
+		}
+		for _, tt := range tests {
+			err := store.SaveUser(tt.user)
+			var conflict *ConflictError
+			if !errors.Is(err, ErrConflict) || !errors.As(err, &conflict) || conflict.Field != tt.field {
+				t.Errorf("conflicting %s: err = %v", tt.field, err)
+			}
+		}
+
+		// The failed writes left nothing behind
+		if user, err := store.GetUserByID("u2"); err != nil || user != nil {
+			t.Errorf("GetUserByID(u2) = %v, %v", user, err)
+		}
+
+		// Saving a user again is not a conflict with itself
+		mustSaveUser(t, store, &User{ID: "u1", Username: "alice", Email: "alice@example.com"})
+	})
+}
+
+func TestStoreUserUpdateAndDelete(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		mustSaveUser(t, store, &User{ID: "u1", Username: "alice", Email: "alice@example.com", OrgID: "org1",
+			OIDCIssuer: "https://idp", OIDCSubject: "sub-1"})
+
+		// Renaming and moving drops the old index entries
+		mustSaveUser(t, store, &User{ID: "u1", Username: "alicia", Email: "alicia@example.com", OrgID: "org2"})
+		if user, _ := store.GetUserByUsername("alice"); user != nil {
+			t.Error("old username still resolves")
+		}
+		if user, _ := store.GetUserByEmail("alice@example.com"); user != nil {
+			t.Error("old email still resolves")
+		}
+		if user, _ := store.GetUserByOIDCSubject("https://idp", "sub-1"); user != nil {
+			t.Error("unlinked identity still resolves")
+		}
+		if users, _ := store.GetUsersByOrgID("org1"); len(users) != 0 {
+			t.Errorf("old organization still lists %d users", len(users))
+		}
+		if user, err := store.GetUserByUsername("alicia"); err != nil || user == nil || user.OrgID != "org2" {
+			t.Errorf("GetUserByUsername(alicia) = %v, %v", user, err)
+		}
+
+		// The old names are free again
+		mustSaveUser(t, store, &User{ID: "u2", Username: "alice", Email: "alice@example.com"})
+
+		// Deleting removes every index entry; deleting twice is fine
+		for i := 0; i < 2; i++ {
+			if err := store.DeleteUser("u1"); err != nil {
+				t.Fatalf("DeleteUser: %v", err)
+			}
+		}
+		if user, _ := store.GetUserByUsername("alicia"); user != nil {
+			t.Error("deleted user still resolves by username")
+		}
+		if users, _ := store.GetUsersByOrgID("org2"); len(users) != 0 {
+			t.Errorf("deleted user still listed in organization")
+		}
+		if user, _ := store.GetUserByUsername("alice"); user == nil || user.ID != "u2" {
+			t.Errorf("other user lost by delete: %v", user)
+		}
+	})
+}
+
+func TestStoreReturnsCopies(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		user := &User{ID: "u1", Username: "alice"}
+		mustSaveUser(t, store, user)
+
+		// Neither the saved nor a loaded record is shared with the store
+		user.Username = "changed"
+		loaded, _ := store.GetUserByID("u1")
+		if loaded.Username != "alice" {
+			t.Fatalf("store saw a change to the saved record: %q", loaded.Username)
+		}
+		loaded.Username = "changed"
+		if again, _ := store.GetUserByUsername("alice"); again == nil || again.Username != "alice" {
+			t.Errorf("store saw a change to a loaded record: %v", again)
+		}
+	})
+}
+
+func TestStoreResumesScopedByOrganization(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		mustSaveResume(t, store, &Resume{ID: "r1", UserID: "u1", OrgID: "org1", Content: "one"})
+		mustSaveResume(t, store, &Resume{ID: "r2", UserID: "u1", OrgID: "org1", Content: "two"})
+		mustSaveResume(t, store, &Resume{ID: "r3", UserID: "u1", OrgID: "", Content: "one"})
+		mustSaveResume(t, store, &Resume{ID: "r4", UserID: "u2", OrgID: "org2", Content: "four"})
+
+		if resume, err := store.GetResumeByID("org1", "r1"); err != nil || resume == nil {
+			t.Errorf("GetResumeByID = %v, %v", resume, err)
+		}
+		if resume, err := store.GetResumeByID("org2", "r1"); err != nil || resume != nil {
+			t.Errorf("GetResumeByID from another org = %v, %v", resume, err)
+		}
+
+		resumes, err := store.GetResumesByUserID("org1", "u1")
+		if got := resumeIDs(resumes); err != nil || !equalIDs(got, []string{"r1", "r2"}) {
+			t.Errorf("GetResumesByUserID(org1) = %v, %v", got, err)
+		}
+		resumes, err = store.GetResumesByUserID("", "u1")
+		if got := resumeIDs(resumes); err != nil || !equalIDs(got, []string{"r3"}) {
+			t.Errorf("GetResumesByUserID(no org) = %v, %v", got, err)
+		}
+		resumes, err = store.GetResumesByOrgID("org2")
+		if got := resumeIDs(resumes); err != nil || !equalIDs(got, []string{"r4"}) {
+			t.Errorf("GetResumesByOrgID(org2) = %v, %v", got, err)
+		}
+
+		// Deleting from another organization is a no-op
+		if err := store.DeleteResume("org2", "r1"); err != nil {
+			t.Fatal(err)
+		}
+		if resume, _ := store.GetResumeByID("org1", "r1"); resume == nil {
+			t.Error("resume deleted through another organization")
+		}
+		if err := store.DeleteResume("org1", "r1"); err != nil {
+			t.Fatal(err)
+		}
+		if resume, _ := store.GetResumeByID("org1", "r1"); resume != nil {
+			t.Error("deleted resume still found")
+		}
+		resumes, _ = store.GetResumesByUserID("org1", "u1")
+		if got := resumeIDs(resumes); !equalIDs(got, []string{"r2"}) {
+			t.Errorf("GetResumesByUserID after delete = %v", got)
+		}
+	})
+}
+
+func TestStoreResumeContentHash(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		resume := &Resume{ID: "r1", UserID: "u1", OrgID: "org1", Content: "same text"}
+		mustSaveResume(t, store, resume)
+		if resume.ContentHash != ContentHash("same text") {
+			t.Fatalf("ContentHash not filled in: %q", resume.ContentHash)
+		}
+
+		hash := ContentHash("same text")
+		if found, err := store.GetResumeByContentHash("org1", hash); err != nil || found == nil || found.ID != "r1" {
+			t.Errorf("GetResumeByContentHash = %v, %v", found, err)
+		}
+		// Duplicates are only detected within an organization
+		if found, err := store.GetResumeByContentHash("org2", hash); err != nil || found != nil {
+			t.Errorf("GetResumeByContentHash from another org = %v, %v", found, err)
+		}
+
+		// Changing the content moves the hash entry
+		mustSaveResume(t, store, &Resume{ID: "r1", UserID: "u1", OrgID: "org1", Content: "new text"})
+		if found, _ := store.GetResumeByContentHash("org1", hash); found != nil {
+			t.Error("old content hash still resolves")
+		}
+		if found, _ := store.GetResumeByContentHash("org1", ContentHash("new text")); found == nil {
+			t.Error("new content hash does not resolve")
+		}
+	})
+}
+
+func TestStoreRefreshTokens(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		expires := time.Now().Add(time.Hour)
+		for _, token := range []*RefreshToken{
+			{Hash: "h1", UserID: "u1", FamilyID: "f1", ExpiresAt: expires},
+			{Hash: "h2", UserID: "u1", FamilyID: "f1", ExpiresAt: expires},
+			{Hash: "h3", UserID: "u1", FamilyID: "f2", ExpiresAt: expires},
+		} {
+			if err := store.SaveRefreshToken(token); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// UseRefreshToken returns the state from before the call
+		first, err := store.UseRefreshToken("h1")
+		if err != nil || first == nil || first.Used {
+			t.Fatalf("first use = %+v, %v", first, err)
+		}
+		second, err := store.UseRefreshToken("h1")
+		if err != nil || second == nil || !second.Used {
+			t.Fatalf("second use = %+v, %v", second, err)
+		}
+		if missing, err := store.UseRefreshToken("missing"); err != nil || missing != nil {
+			t.Errorf("UseRefreshToken(missing) = %+v, %v", missing, err)
+		}
+
+		// Revoking a family leaves other families alone
+		if err := store.RevokeRefreshTokenFamily("f1"); err != nil {
+			t.Fatal(err)
+		}
+		if token, _ := store.UseRefreshToken("h2"); token == nil || !token.Revoked {
+			t.Errorf("family member not revoked: %+v", token)
+		}
+		if token, _ := store.UseRefreshToken("h3"); token == nil || token.Revoked {
+			t.Errorf("other family revoked: %+v", token)
+		}
+	})
+}
+
+func TestStoreAccessTokenDenylist(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		if err := store.RevokeAccessToken("jti-1", time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if revoked, err := store.IsAccessTokenRevoked("jti-1"); err != nil || !revoked {
+			t.Errorf("IsAccessTokenRevoked(jti-1) = %v, %v", revoked, err)
+		}
+		if revoked, err := store.IsAccessTokenRevoked("jti-2"); err != nil || revoked {
+			t.Errorf("IsAccessTokenRevoked(jti-2) = %v, %v", revoked, err)
+		}
+	})
+}
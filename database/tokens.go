@@ -0,0 +1,188 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// RefreshToken is a server-side refresh token record.
+// Only the SHA-256 hash of the token is stored; rotated tokens share a FamilyID.
+type RefreshToken struct {
+	Hash      string
+	UserID    string
+	FamilyID  string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Used      bool
+	Revoked   bool
+}
+
+// Bucket names for token state in the bolt store
+var (
+	refreshTokensBucket = []byte("refresh_tokens") // hash -> RefreshToken
+	revokedTokensBucket = []byte("revoked_tokens") // access token jti -> expiry (RFC 3339)
+)
+
+// SaveRefreshToken stores a refresh token record
+func (s *MemoryStore) SaveRefreshToken(token *RefreshToken) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.refreshTokens[token.Hash] = token
+	return nil
+}
+
+// UseRefreshToken marks a refresh token as used and returns its state from
+// before the call, or nil if no such token exists
+func (s *MemoryStore) UseRefreshToken(hash string) (*RefreshToken, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	token, exists := s.refreshTokens[hash]
+	if !exists {
+		return nil, nil
+	}
+	prior := *token
+	token.Used = true
+	return &prior, nil
+}
+
+// RevokeRefreshTokenFamily revokes every refresh token in a family
+func (s *MemoryStore) RevokeRefreshTokenFamily(familyID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	for hash, token := range s.refreshTokens {
+		if token.ExpiresAt.Before(now) {
+			delete(s.refreshTokens, hash)
+		} else if token.FamilyID == familyID {
+			token.Revoked = true
+		}
+	}
+	return nil
+}
+
+// RevokeAccessToken adds an access token ID to the denylist until it expires
+func (s *MemoryStore) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	for id, expiry := range s.revokedTokens {
+		if expiry.Before(now) {
+			delete(s.revokedTokens, id)
+		}
+	}
+	s.revokedTokens[jti] = expiresAt
+	return nil
+}
+
+// IsAccessTokenRevoked reports whether an access token ID is on the denylist
+func (s *MemoryStore) IsAccessTokenRevoked(jti string) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	_, revoked := s.revokedTokens[jti]
+	return revoked, nil
+}
+
+// SaveRefreshToken stores a refresh token record
+func (s *BoltStore) SaveRefreshToken(token *RefreshToken) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, refreshTokensBucket, token.Hash, token)
+	})
+}
+
+// UseRefreshToken marks a refresh token as used and returns its state from
+// before the call, or nil if no such token exists
+func (s *BoltStore) UseRefreshToken(hash string) (*RefreshToken, error) {
+	var prior *RefreshToken
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var token RefreshToken
+		found, err := get(tx, refreshTokensBucket, hash, &token)
+		if err != nil || !found {
+			return err
+		}
+		saved := token
+		prior = &saved
+		token.Used = true
+		return put(tx, refreshTokensBucket, hash, &token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prior, nil
+}
+
+// RevokeRefreshTokenFamily revokes every refresh token in a family
+func (s *BoltStore) RevokeRefreshTokenFamily(familyID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		// Collect first; bolt forbids writes to a bucket during ForEach
+		var revoke []*RefreshToken
+		var expired [][]byte
+		now := time.Now()
+		err := tx.Bucket(refreshTokensBucket).ForEach(func(k, v []byte) error {
+			var token RefreshToken
+			if err := json.Unmarshal(v, &token); err != nil {
+				return err
+			}
+			if token.ExpiresAt.Before(now) {
+				expired = append(expired, append([]byte(nil), k...))
+			} else if token.FamilyID == familyID && !token.Revoked {
+				revoke = append(revoke, &token)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			if err := tx.Bucket(refreshTokensBucket).Delete(key); err != nil {
+				return err
+			}
+		}
+		for _, token := range revoke {
+			token.Revoked = true
+			if err := put(tx, refreshTokensBucket, token.Hash, token); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RevokeAccessToken adds an access token ID to the denylist until it expires
+func (s *BoltStore) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(revokedTokensBucket)
+
+		// Drop entries for tokens that have expired anyway
+		var expired [][]byte
+		now := time.Now()
+		err := b.ForEach(func(k, v []byte) error {
+			if expiry, err := time.Parse(time.RFC3339, string(v)); err == nil && expiry.Before(now) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range expired {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return b.Put([]byte(jti), []byte(expiresAt.UTC().Format(time.RFC3339)))
+	})
+}
+
+// IsAccessTokenRevoked reports whether an access token ID is on the denylist
+func (s *BoltStore) IsAccessTokenRevoked(jti string) (bool, error) {
+	var revoked bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		revoked = tx.Bucket(revokedTokensBucket).Get([]byte(jti)) != nil
+		return nil
+	})
+	return revoked, err
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"webscrapper/models"
+	"webscrapper/utils"
+)
+
+// MailOptions configure outgoing account email
+type MailOptions struct {
+	Mailer  utils.Mailer
+	BaseURL string // Public URL of the app, used to build links
+
+	// RequireVerifiedEmail blocks uploads until the user verifies their email
+	RequireVerifiedEmail bool
+}
+
+// mailOptions holds the options passed to ConfigureMail
+var mailOptions = MailOptions{
+	Mailer:  &utils.MemoryMailer{},
+	BaseURL: "http://localhost:8081",
+}
+
+// ConfigureMail sets the mailer and link settings for account email
+func ConfigureMail(options MailOptions) {
+	options.BaseURL = strings.TrimRight(options.BaseURL, "/")
+	mailOptions = options
+}
+
+// ForgotPasswordRequest represents the forgot password request body
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest represents the password reset request body
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// VerifyEmailRequest represents the email verification request body
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// sendPasswordReset emails a password reset link. Accounts without a local
+// password are skipped.
+func sendPasswordReset(user *models.User) error {
+	binding, err := models.PasswordResetBinding(user.ID)
+	if err != nil || binding == "" {
+		return err
+	}
+	token, err := utils.GeneratePurposeToken(utils.PurposePasswordReset, user.ID, binding, utils.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+	return mailOptions.Mailer.Send(utils.Message{
+		To:      user.Email,
+		Subject: "Reset your Resume Analyzer password",
+		Body: "Someone asked to reset the password of your Resume Analyzer account " + user.Username + ".\n\n" +
+			"Choose a new password here within the next hour:\n\n" +
+			mailOptions.BaseURL + "/app#reset=" + token + "\n\n" +
+			"If this wasn't you, you can ignore this email.\n",
+	})
+}
+
+// sendEmailVerification emails a link confirming the user's address
+func sendEmailVerification(user *models.User) error {
+	binding, err := models.EmailVerificationBinding(user.ID)
+	if err != nil || binding == "" {
+		return err
+	}
+	token, err := utils.GeneratePurposeToken(utils.PurposeVerifyEmail, user.ID, binding, utils.VerifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	return mailOptions.Mailer.Send(utils.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: "Welcome to Resume Analyzer, " + user.Username + ".\n\n" +
+			"Confirm your email address by opening this link:\n\n" +
+			mailOptions.BaseURL + "/app#verify=" + token + "\n",
+	})
+}
+
+// ForgotPasswordHandler emails a password reset link. It answers the same
+// way whether or not the address belongs to an account.
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUserByEmail(req.Email)
+	if err != nil {
+		http.Error(w, "Failed to send reset email", http.StatusInternalServerError)
+		return
+	}
+	if user != nil {
+		// Send in the background so the response time does not reveal
+		// whether an account exists
+		go func() {
+			if err := sendPasswordReset(user); err != nil {
+				log.Printf("Error sending password reset to user %s: %v", user.ID, err)
+			}
+		}()
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPasswordHandler sets a new password using a reset link's token
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" || req.Password == "" {
+		http.Error(w, "Token and password are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Password) > models.MaxPasswordLength {
+		http.Error(w, "Password is too long", http.StatusBadRequest)
+		return
+	}
+
+	// Check the token
+	claims, err := utils.ValidatePurposeToken(req.Token, utils.PurposePasswordReset)
+	if err != nil {
+		http.Error(w, "Invalid or expired link", http.StatusBadRequest)
+		return
+	}
+
+	// Set the password
+	err = models.ResetPassword(claims.UserID, claims.Binding, req.Password)
+	if errors.Is(err, models.ErrInvalidAccountLink) {
+		http.Error(w, "Invalid or expired link", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	auditAccount(r, claims.UserID, models.AuditPasswordReset)
+	if err := models.RevokeAccessToken(claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		log.Println("Error revoking password reset token:", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmailHandler confirms an email address using a verification link's
+// token
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	// Check the token
+	claims, err := utils.ValidatePurposeToken(req.Token, utils.PurposeVerifyEmail)
+	if err != nil {
+		http.Error(w, "Invalid or expired link", http.StatusBadRequest)
+		return
+	}
+
+	// Mark the address verified
+	err = models.VerifyEmail(claims.UserID, claims.Binding)
+	if errors.Is(err, models.ErrInvalidAccountLink) {
+		http.Error(w, "Invalid or expired link", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+	auditAccount(r, claims.UserID, models.AuditEmailVerified)
+	if err := models.RevokeAccessToken(claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		log.Println("Error revoking email verification token:", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerificationHandler emails the caller a new verification link
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := models.GetUserByID(principal.UserID)
+	if err != nil || user == nil {
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+	if user.Email == "" {
+		http.Error(w, "Your account has no email address", http.StatusBadRequest)
+		return
+	}
+	if user.EmailVerified {
+		http.Error(w, "Email is already verified", http.StatusConflict)
+		return
+	}
+
+	if err := sendEmailVerification(user); err != nil {
+		log.Printf("Error sending verification email to user %s: %v", user.ID, err)
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// auditAccount records an event a user caused through an emailed link,
+// filed under the user's organization
+func auditAccount(r *http.Request, userID, action string) {
+	event := models.AuditEvent{
+		Action:     action,
+		ActorID:    userID,
+		TargetType: "user",
+		TargetID:   userID,
+	}
+	if user, err := models.GetUserByID(userID); err == nil && user != nil {
+		event.OrgID = user.OrgID
+	}
+	audit(r, event)
+}
+
+// requireVerifiedEmail answers 403 when uploads need a verified email and
+// the user has not verified theirs
+func requireVerifiedEmail(w http.ResponseWriter, userID string) bool {
+	if !mailOptions.RequireVerifiedEmail {
+		return true
+	}
+	user, err := models.GetUserByID(userID)
+	if err != nil || user == nil {
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return false
+	}
+	if !user.EmailVerified {
+		http.Error(w, "Verify your email address first", http.StatusForbidden)
+		return false
+	}
+	return true
+}
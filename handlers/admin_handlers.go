@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	"webscrapper/models"
+)
+
+// SnapshotRequest represents the snapshot and restore request body
+type SnapshotRequest struct {
+	File string `json:"file"`
+}
+
+// SnapshotResponse represents the snapshot and restore response
+type SnapshotResponse struct {
+	File string `json:"file"`
+}
+
+// SnapshotHandler writes a snapshot of the in-memory database.
+// An empty body snapshots to the configured snapshot file.
+func SnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse optional request body
+	var req SnapshotRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	path, err := models.SaveSnapshot(req.File)
+	if errors.Is(err, models.ErrSnapshotsUnsupported) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to write snapshot", http.StatusInternalServerError)
+		return
+	}
+	audit(r, models.AuditEvent{
+		Action:     models.AuditExport,
+		TargetType: "snapshot",
+		TargetID:   path,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SnapshotResponse{File: path})
+}
+
+// RestoreHandler replaces the in-memory database with a snapshot file
+func RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req SnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.File == "" {
+		http.Error(w, "Snapshot file is required", http.StatusBadRequest)
+		return
+	}
+
+	path, err := models.RestoreSnapshot(req.File)
+	switch {
+	case errors.Is(err, models.ErrSnapshotsUnsupported):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, os.ErrNotExist):
+		http.Error(w, "Snapshot file not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "Failed to restore snapshot", http.StatusInternalServerError)
+		return
+	}
+	audit(r, models.AuditEvent{
+		Action:     models.AuditRestore,
+		TargetType: "snapshot",
+		TargetID:   path,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SnapshotResponse{File: path})
+}
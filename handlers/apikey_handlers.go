@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"webscrapper/models"
+	"webscrapper/utils"
+)
+
+// CreateAPIKeyRequest represents the API key creation request body
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreateAPIKeyResponse returns a new API key. Key is the secret and is
+// shown only in this response.
+type CreateAPIKeyResponse struct {
+	Key    string         `json:"key"`
+	APIKey *models.APIKey `json:"api_key"`
+}
+
+// APIKeyPrincipal resolves a raw API key to the principal it acts as.
+// The key acts with its owner's current role, limited to the key's scopes.
+func APIKeyPrincipal(secret string) (*utils.Principal, error) {
+	key, user, err := models.AuthenticateAPIKey(secret)
+	if err != nil || key == nil {
+		return nil, err
+	}
+	return &utils.Principal{
+		UserID:   user.ID,
+		Roles:    []string{user.Role},
+		OrgID:    user.OrgID,
+		APIKeyID: key.ID,
+		Scopes:   key.Scopes,
+	}, nil
+}
+
+// CreateAPIKeyHandler creates an API key for the caller
+func CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	// Create the key in the caller's current organization
+	secret, key, err := models.CreateAPIKey(principal.UserID, principal.OrgID, req.Name, req.Scopes)
+	if errors.Is(err, models.ErrInvalidScope) {
+		http.Error(w, "Scopes must be one or more of resumes:read, resumes:write, jobs:read, jobs:write", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+	auditPrincipal(r, principal, models.AuditAPIKeyCreate, "api_key", key.ID, map[string]string{
+		"scopes": strings.Join(key.Scopes, " "),
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreateAPIKeyResponse{Key: secret, APIKey: key})
+}
+
+// ListAPIKeysHandler lists the caller's API keys
+func ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get the caller's keys
+	keys, err := models.GetAPIKeys(principal.OrgID, principal.UserID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve API keys", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// ListOrgAPIKeysHandler lists every API key in the caller's organization
+func ListOrgAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !authorize(principal, ActionManageOrg, Resource{OrgID: principal.OrgID}) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Get the organization's keys
+	keys, err := models.GetAPIKeys(principal.OrgID, "")
+	if err != nil {
+		http.Error(w, "Failed to retrieve API keys", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKeyHandler revokes an API key owned by the caller, or any key of
+// their organization for admins
+func RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get the key
+	key, err := models.GetAPIKeyByID(chi.URLParam(r, "id"))
+	if err != nil || key == nil {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	if !authorize(principal, ActionManageAPIKey, Resource{OwnerID: key.UserID, OrgID: key.OrgID}) {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	// Revoke it
+	if err := models.RevokeAPIKey(key.ID); err != nil {
+		http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+		return
+	}
+	auditPrincipal(r, principal, models.AuditAPIKeyRevoke, "api_key", key.ID, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
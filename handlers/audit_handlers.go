@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webscrapper/models"
+	"webscrapper/utils"
+)
+
+// Audit log page sizes
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 200
+)
+
+// AuditLogResponse is a page of audit events, newest first. Pass
+// NextCursor as the cursor parameter to get the following page.
+type AuditLogResponse struct {
+	Events     []*models.AuditEvent `json:"events"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// audit records an event caused by a request, adding its IP and user agent
+func audit(r *http.Request, event models.AuditEvent) {
+	event.IP = utils.ClientIP(r)
+	event.UserAgent = r.UserAgent()
+	models.RecordAudit(event)
+}
+
+// auditPrincipal records an event performed by the authenticated caller
+func auditPrincipal(r *http.Request, principal *utils.Principal, action, targetType, targetID string, details map[string]string) {
+	if principal.IsAPIKey() {
+		if details == nil {
+			details = map[string]string{}
+		}
+		details["api_key_id"] = principal.APIKeyID
+	}
+	audit(r, models.AuditEvent{
+		Action:     action,
+		ActorID:    principal.UserID,
+		OrgID:      principal.OrgID,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+	})
+}
+
+// auditLogin records a completed login
+func auditLogin(r *http.Request, user *models.User, method string) {
+	audit(r, models.AuditEvent{
+		Action:     models.AuditLogin,
+		ActorID:    user.ID,
+		OrgID:      user.OrgID,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Details:    map[string]string{"method": method},
+	})
+}
+
+// auditLoginFailed records a failed login. user is nil when the username
+// does not exist.
+func auditLoginFailed(r *http.Request, username string, user *models.User, reason string) {
+	event := models.AuditEvent{
+		Action:     models.AuditLoginFailed,
+		TargetType: "username",
+		TargetID:   username,
+		Details:    map[string]string{"reason": reason},
+	}
+	if user != nil {
+		event.OrgID, event.TargetType, event.TargetID = user.OrgID, "user", user.ID
+		event.Details["username"] = username
+	}
+	audit(r, event)
+}
+
+// RecordLockout records a login lockout raised by the login throttle
+func RecordLockout(kind, subject string, until time.Time) {
+	event := models.AuditEvent{
+		Action:     models.AuditLockout,
+		TargetType: kind,
+		TargetID:   subject,
+		Details:    map[string]string{"until": until.Format(time.RFC3339)},
+	}
+	switch kind {
+	case "ip":
+		event.IP = subject
+	case "username":
+		// File the event with the account's organization when it exists
+		if user, err := models.GetUserByUsername(subject); err == nil && user != nil {
+			event.TargetType, event.TargetID, event.OrgID = "user", user.ID, user.OrgID
+			event.Details["username"] = subject
+		}
+	}
+	models.RecordAudit(event)
+}
+
+// AuditLogHandler lists the audit events of the caller's organization
+func AuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !authorize(principal, ActionViewAudit, Resource{OrgID: principal.OrgID}) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	writeAuditLog(w, r, models.AuditFilter{OrgID: principal.OrgID})
+}
+
+// AdminAuditLogHandler lists audit events across all organizations, or of
+// the one named by the org_id parameter
+func AdminAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	filter := models.AuditFilter{AllOrgs: true}
+	if orgID, ok := r.URL.Query()["org_id"]; ok {
+		filter.AllOrgs = false
+		filter.OrgID = orgID[0]
+	}
+	writeAuditLog(w, r, filter)
+}
+
+// writeAuditLog applies the query filters to filter and sends a page of
+// matching events
+func writeAuditLog(w http.ResponseWriter, r *http.Request, filter models.AuditFilter) {
+	query := r.URL.Query()
+	filter.Action = query.Get("action")
+	filter.ActorID = query.Get("actor_id")
+	filter.TargetID = query.Get("target_id")
+
+	// Time range
+	var err error
+	if since := query.Get("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			http.Error(w, "until must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Paging
+	limit := defaultAuditLimit
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			http.Error(w, "limit must be between 1 and 200", http.StatusBadRequest)
+			return
+		}
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		if filter.Before, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+	filter.Limit = limit + 1 // One extra tells whether another page exists
+
+	events, err := models.GetAuditEvents(filter)
+	if err != nil {
+		http.Error(w, "Failed to load audit log", http.StatusInternalServerError)
+		return
+	}
+	response := AuditLogResponse{Events: events}
+	if len(events) > limit {
+		response.Events = events[:limit]
+		response.NextCursor = strconv.FormatUint(events[limit-1].Seq, 10)
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -2,7 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+
 	"webscrapper/models"
 	"webscrapper/utils"
 )
@@ -20,10 +26,99 @@ type RegisterRequest struct {
 	Email    string `json:"email"`
 }
 
+// RefreshRequest represents the token refresh request body
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 // AuthResponse represents the authentication response
 type AuthResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refresh_token"`
+	ExpiresIn    int          `json:"expires_in"` // Access token lifetime in seconds
+	User         *models.User `json:"user,omitempty"`
+
+	// Set when TOTP was enabled during this login; shown only once
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// userPrincipal describes a user's identity for a new access token
+func userPrincipal(user *models.User, familyID string) utils.Principal {
+	return utils.Principal{
+		UserID:   user.ID,
+		Roles:    []string{user.Role},
+		OrgID:    user.OrgID,
+		FamilyID: familyID,
+	}
+}
+
+// issueTokens creates an access and refresh token pair for a user.
+// An empty familyID starts a new login session.
+func issueTokens(user *models.User, familyID string) (*AuthResponse, error) {
+	refreshToken, familyID, err := models.IssueRefreshToken(user.ID, familyID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := utils.GenerateToken(userPrincipal(user, familyID))
+	if err != nil {
+		return nil, err
+	}
+	return &AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// reserveLoginAttempt reserves a password or code check with the throttle.
+// It answers 429 with Retry-After when the username or the client's IP has
+// failed too often and must wait before trying again. A reserved attempt
+// ends with recordLoginFailure, recordLoginSuccess or releaseLoginAttempt.
+func reserveLoginAttempt(w http.ResponseWriter, r *http.Request, username string) bool {
+	if authOptions.Throttle == nil {
+		return true
+	}
+	wait, err := authOptions.Throttle.Reserve(username, utils.ClientIP(r))
+	if err != nil {
+		http.Error(w, "Failed to log in", http.StatusInternalServerError)
+		return false
+	}
+	if wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		http.Error(w, fmt.Sprintf("Too many failed login attempts, try again in %d seconds", seconds), http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure counts a failed password or code towards the throttle
+func recordLoginFailure(r *http.Request, username string) {
+	if authOptions.Throttle == nil {
+		return
+	}
+	if err := authOptions.Throttle.Failure(username, utils.ClientIP(r)); err != nil {
+		log.Println("Error recording failed login:", err)
+	}
+}
+
+// releaseLoginAttempt ends a reserved attempt that neither failed nor
+// completed a login
+func releaseLoginAttempt(r *http.Request, username string) {
+	if authOptions.Throttle == nil {
+		return
+	}
+	authOptions.Throttle.Release(username, utils.ClientIP(r))
+}
+
+// recordLoginSuccess clears the username's failures once a login completes
+func recordLoginSuccess(r *http.Request, username string) {
+	if authOptions.Throttle == nil {
+		return
+	}
+	if err := authOptions.Throttle.Success(username, utils.ClientIP(r)); err != nil {
+		log.Println("Error resetting failed logins:", err)
+	}
 }
 
 // LoginHandler handles user login
@@ -48,36 +143,51 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Slow down password guessing
+	if !reserveLoginAttempt(w, r, req.Username) {
+		return
+	}
+
 	// Get user by username
 	user, err := models.GetUserByUsername(req.Username)
 	if err != nil {
+		releaseLoginAttempt(r, req.Username)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
-	// Verify password
+	// Verify password (a nil user still costs a hash comparison)
 	if !models.VerifyPassword(user, req.Password) {
+		recordLoginFailure(r, req.Username)
+		auditLoginFailed(r, req.Username, user, "password")
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID)
+	// Ask for a second factor before issuing tokens
+	user.Password = ""
+	mfaRequired, err := models.MFARequired(user.ID)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		releaseLoginAttempt(r, req.Username)
+		http.Error(w, "Failed to log in", http.StatusInternalServerError)
 		return
 	}
+	if mfaRequired {
+		// The code is checked, and throttled, by the second step
+		releaseLoginAttempt(r, req.Username)
+		writeMFAChallenge(w, user)
+		return
+	}
+	recordLoginSuccess(r, req.Username)
+	auditLogin(r, user, "password")
 
-	// Create response
-	response := AuthResponse{
-		Token: token,
-		User: &models.User{
-			ID:        user.ID,
-			Username:  user.Username,
-			Email:     user.Email,
-			CreatedAt: user.CreatedAt,
-		},
+	// Generate tokens
+	response, err := issueTokens(user, "")
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
 	}
+	response.User = user
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
@@ -105,29 +215,129 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Username, password, and email are required", http.StatusBadRequest)
 		return
 	}
+	if len(req.Password) > models.MaxPasswordLength {
+		http.Error(w, "Password is too long", http.StatusBadRequest)
+		return
+	}
 
 	// Create user
 	user, err := models.CreateUser(req.Username, req.Password, req.Email)
+	var conflict *models.ConflictError
+	if errors.As(err, &conflict) {
+		writeError(w, http.StatusConflict, ErrorResponse{
+			Error:   "conflict",
+			Message: conflict.Error(),
+			Field:   conflict.Field,
+		})
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID)
+	audit(r, models.AuditEvent{
+		Action:     models.AuditRegister,
+		ActorID:    user.ID,
+		TargetType: "user",
+		TargetID:   user.ID,
+	})
+
+	// Ask the user to confirm their address
+	go func() {
+		if err := sendEmailVerification(user); err != nil {
+			log.Printf("Error sending verification email to user %s: %v", user.ID, err)
+		}
+	}()
+
+	// Generate tokens
+	response, err := issueTokens(user, "")
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
-
-	// Create response
-	response := AuthResponse{
-		Token: token,
-		User:  user,
-	}
+	response.User = user
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
 }
+
+// RefreshHandler exchanges a refresh token for a new token pair.
+// The presented refresh token is consumed and must not be used again.
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	// Rotate the refresh token
+	userID, familyID, refreshToken, err := models.RotateRefreshToken(req.RefreshToken)
+	if errors.Is(err, models.ErrInvalidRefreshToken) || errors.Is(err, models.ErrRefreshTokenReused) {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	// Reload the user so role changes take effect on refresh
+	user, err := models.GetUserByID(userID)
+	if err != nil || user == nil {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	
+	// Members must log in again once their organization requires MFA
+	setupRequired, err := models.MFASetupRequired(user.ID)
+	if err != nil || setupRequired {
+		models.RevokeTokenFamily(familyID)
+		http.Error(w, "Two-factor authentication is required, please log in again", http.StatusUnauthorized)
+		return
+	}
+
+	// Generate a new access token in the same session
+	token, err := utils.GenerateToken(userPrincipal(user, familyID))
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
+	})
+}
+
+// LogoutHandler revokes the caller's access token and every refresh token
+// of the login session it belongs to
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Revoke the refresh token family and the access token itself
+	if principal.FamilyID != "" {
+		if err := models.RevokeTokenFamily(principal.FamilyID); err != nil {
+			http.Error(w, "Failed to log out", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := models.RevokeAccessToken(principal.TokenID, principal.ExpiresAt); err != nil {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	auditPrincipal(r, principal, models.AuditLogout, "user", principal.UserID, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"webscrapper/models"
+	"webscrapper/utils"
+)
+
+// JobRequest represents the job create and update request body; omitted
+// fields are kept on update
+type JobRequest struct {
+	Title            *string           `json:"title"`
+	Description      *string           `json:"description"`
+	RequiredSkills   *[]string         `json:"required_skills"`
+	NiceToHaveSkills *[]string         `json:"nice_to_have_skills"`
+	MinYears         *int              `json:"min_years"`
+	MinEducation     *string           `json:"min_education"`
+	Rubric           *models.JobRubric `json:"rubric"`
+}
+
+// JobMatch is one resume's fit for a job
+type JobMatch struct {
+	ResumeID      string `json:"resume_id"`
+	UserID        string `json:"user_id"`
+	Filename      string `json:"filename"`
+	Title         string `json:"title,omitempty"`
+	CandidateName string `json:"candidate_name,omitempty"`
+	utils.MatchResult
+}
+
+// JobMatchesResponse represents the resumes ranked against a job and the
+// rubric that weighed their scores
+type JobMatchesResponse struct {
+	JobID        string       `json:"job_id"`
+	Rubric       utils.Rubric `json:"rubric"`
+	MinEducation string       `json:"min_education,omitempty"`
+	Matches      []JobMatch   `json:"matches"`
+}
+
+// jobUpdate converts a job request, analyzing the description when it is set
+func jobUpdate(req JobRequest, orgID string) models.JobUpdate {
+	update := models.JobUpdate{
+		Title:            req.Title,
+		Description:      req.Description,
+		RequiredSkills:   req.RequiredSkills,
+		NiceToHaveSkills: req.NiceToHaveSkills,
+		MinYears:         req.MinYears,
+		MinEducation:     req.MinEducation,
+		Rubric:           req.Rubric,
+	}
+	if req.Description != nil {
+		// Same extraction as resumes, with the organization's skill dictionary
+		keywords, skills, education, _ := utils.AnalyzeResumeWithSkills(*req.Description, models.SkillDictionary(orgID))
+		update.Analysis = &models.JobAnalysis{Keywords: keywords, Skills: skills, Education: education}
+	}
+	return update
+}
+
+// validJobRequest checks the request fields the job model leaves to its
+// callers. It writes the error response and returns false otherwise.
+func validJobRequest(w http.ResponseWriter, req JobRequest) bool {
+	if req.MinEducation != nil && *req.MinEducation != "" && !utils.ValidEducationLevel(*req.MinEducation) {
+		http.Error(w, "min_education must be one of "+strings.Join(utils.EducationLevels, ", "), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeJobError reports a failed job create or update
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrJobTitleRequired), errors.Is(err, models.ErrJobTitleLength),
+		errors.Is(err, models.ErrJobDescriptionLength), errors.Is(err, models.ErrJobSkills),
+		errors.Is(err, models.ErrJobMinYears), errors.Is(err, models.ErrJobRubric):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to save job", http.StatusInternalServerError)
+	}
+}
+
+// jobForAction loads the job named in the URL and checks the caller may
+// perform action on it. It writes the error response and returns nil
+// otherwise.
+func jobForAction(w http.ResponseWriter, r *http.Request, principal *utils.Principal, action Action) *models.Job {
+	// Get job (only jobs of the caller's organization are visible)
+	job, err := models.GetJobByID(principal.OrgID, chi.URLParam(r, "id"))
+	if err != nil || job == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return nil
+	}
+
+	// Check the caller may act on the job
+	if !authorize(principal, action, jobResource(job)) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil
+	}
+	return job
+}
+
+// CreateJobHandler creates a job description in the caller's organization
+func CreateJobHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validJobRequest(w, req) {
+		return
+	}
+
+	// Create the job
+	job, err := models.CreateJob(principal.UserID, principal.OrgID, jobUpdate(req, principal.OrgID))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	auditPrincipal(r, principal, models.AuditJobCreate, "job", job.ID, map[string]string{
+		"title": job.Title,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetJobsHandler lists the jobs of the caller's organization, or the
+// caller's own jobs when they have no organization
+func GetJobsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ownerID := ""
+	if principal.OrgID == "" {
+		ownerID = principal.UserID
+	}
+	jobs, err := models.GetJobs(principal.OrgID, ownerID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve jobs", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// GetJobHandler retrieves a specific job
+func GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	job := jobForAction(w, r, principal, ActionViewJob)
+	if job == nil {
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// UpdateJobHandler changes a job; a new description is analyzed again
+func UpdateJobHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validJobRequest(w, req) {
+		return
+	}
+
+	job := jobForAction(w, r, principal, ActionManageJob)
+	if job == nil {
+		return
+	}
+
+	// Apply the changes
+	updated, err := models.UpdateJob(job.OrgID, job.ID, jobUpdate(req, job.OrgID))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	if updated == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	// Record which fields changed
+	var changed []string
+	if req.Title != nil {
+		changed = append(changed, "title")
+	}
+	if req.Description != nil {
+		changed = append(changed, "description")
+	}
+	if req.RequiredSkills != nil {
+		changed = append(changed, "required_skills")
+	}
+	if req.NiceToHaveSkills != nil {
+		changed = append(changed, "nice_to_have_skills")
+	}
+	if req.MinYears != nil {
+		changed = append(changed, "min_years")
+	}
+	if req.MinEducation != nil {
+		changed = append(changed, "min_education")
+	}
+	if req.Rubric != nil {
+		changed = append(changed, "rubric")
+	}
+	auditPrincipal(r, principal, models.AuditJobUpdate, "job", job.ID, map[string]string{
+		"owner_id": job.OwnerID,
+		"fields":   strings.Join(changed, " "),
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteJobHandler deletes a job
+func DeleteJobHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	job := jobForAction(w, r, principal, ActionManageJob)
+	if job == nil {
+		return
+	}
+
+	if err := models.DeleteJob(job.OrgID, job.ID); err != nil {
+		http.Error(w, "Failed to delete job", http.StatusInternalServerError)
+		return
+	}
+	auditPrincipal(r, principal, models.AuditJobDelete, "job", job.ID, map[string]string{
+		"owner_id": job.OwnerID,
+		"title":    job.Title,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JobMatchesHandler ranks every resume the caller may see against a job,
+// best match first, with each score broken down by the job's rubric and
+// the resume sentences that earned it. Recruiters and admins see their
+// organization's resumes; everyone else only their own.
+func JobMatchesHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	job := jobForAction(w, r, principal, ActionViewJob)
+	if job == nil {
+		return
+	}
+
+	// Collect the accessible resumes
+	orgWide := authorize(principal, ActionListOrgResumes, Resource{OrgID: principal.OrgID})
+	var resumes []*models.Resume
+	var err error
+	if orgWide {
+		resumes, err = models.GetResumesByOrgID(principal.OrgID)
+	} else {
+		resumes, err = models.GetResumesByUserID(principal.OrgID, principal.UserID)
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve resumes", http.StatusInternalServerError)
+		return
+	}
+
+	// Without listed skills, match on the skills found in the description,
+	// and without a set education level on the lowest one it names
+	requirements := utils.JobRequirements{
+		RequiredSkills:   job.RequiredSkills,
+		NiceToHaveSkills: job.NiceToHaveSkills,
+		MinYears:         job.MinYears,
+		MinEducation:     job.MinEducation,
+		Keywords:         job.Keywords,
+		Rubric:           &utils.DefaultRubric,
+	}
+	if len(requirements.RequiredSkills) == 0 && len(requirements.NiceToHaveSkills) == 0 {
+		requirements.NiceToHaveSkills = job.DetectedSkills
+	}
+	if requirements.MinEducation == "" {
+		requirements.MinEducation = utils.LowestEducationLevel(job.Description)
+	}
+	if job.Rubric != nil {
+		requirements.Rubric = &utils.Rubric{
+			RequiredSkills:   job.Rubric.RequiredSkills,
+			NiceToHaveSkills: job.Rubric.NiceToHaveSkills,
+			Experience:       job.Rubric.Experience,
+			Education:        job.Rubric.Education,
+			Keywords:         job.Rubric.Keywords,
+		}
+	}
+
+	// Score each resume
+	matches := []JobMatch{}
+	for _, resume := range resumes {
+		matches = append(matches, JobMatch{
+			ResumeID:      resume.ID,
+			UserID:        resume.UserID,
+			Filename:      resume.Filename,
+			Title:         resume.Title,
+			CandidateName: resume.CandidateName,
+			MatchResult:   utils.MatchResume(requirements, resume.Skills, resume.Content),
+		})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ResumeID < matches[j].ResumeID
+	})
+	if orgWide {
+		auditPrincipal(r, principal, models.AuditJobMatches, "job", job.ID, map[string]string{
+			"count": strconv.Itoa(len(matches)),
+		})
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(JobMatchesResponse{
+		JobID:        job.ID,
+		Rubric:       *requirements.Rubric,
+		MinEducation: requirements.MinEducation,
+		Matches:      matches,
+	})
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"webscrapper/models"
+	"webscrapper/utils"
+)
+
+// MFAChallengeResponse is returned by LoginHandler instead of tokens when a
+// second factor is needed. EnrollmentRequired means the user's organization
+// requires MFA and the user must enroll before logging in.
+type MFAChallengeResponse struct {
+	MFARequired        bool   `json:"mfa_required"`
+	MFAToken           string `json:"mfa_token"`
+	EnrollmentRequired bool   `json:"enrollment_required,omitempty"`
+	ExpiresIn          int    `json:"expires_in"` // Challenge lifetime in seconds
+}
+
+// MFALoginRequest represents the second login step request body
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+// MFACodeRequest represents a request body carrying a TOTP or recovery code
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// RecoveryCodesResponse returns recovery codes, which are shown only once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// writeMFAChallenge answers the password step of a login with an MFA
+// challenge token
+func writeMFAChallenge(w http.ResponseWriter, user *models.User) {
+	setupRequired, err := models.MFASetupRequired(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to log in", http.StatusInternalServerError)
+		return
+	}
+	mfaToken, err := utils.GenerateMFAToken(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MFAChallengeResponse{
+		MFARequired:        true,
+		MFAToken:           mfaToken,
+		EnrollmentRequired: setupRequired,
+		ExpiresIn:          int(utils.MFATokenTTL.Seconds()),
+	})
+}
+
+// mfaChallenge validates the challenge token of a login's second step and
+// returns the user it was issued to
+func mfaChallenge(w http.ResponseWriter, mfaToken string) (*models.User, *utils.Claims, bool) {
+	claims, err := utils.ValidateMFAToken(mfaToken)
+	if err != nil {
+		http.Error(w, "Invalid or expired MFA token", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+	user, err := models.GetUserByID(claims.UserID)
+	if err != nil || user == nil {
+		http.Error(w, "Invalid or expired MFA token", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+	return user, claims, true
+}
+
+// completeMFALogin retires the challenge token and issues the real tokens
+func completeMFALogin(w http.ResponseWriter, user *models.User, claims *utils.Claims, recoveryCodes []string) {
+	// A challenge token only works once
+	if err := models.RevokeAccessToken(claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		http.Error(w, "Failed to log in", http.StatusInternalServerError)
+		return
+	}
+
+	// Generate tokens
+	response, err := issueTokens(user, "")
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	response.User = user
+	response.RecoveryCodes = recoveryCodes
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// MFALoginHandler completes a login with a TOTP or recovery code
+func MFALoginHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+		http.Error(w, "MFA token and code are required", http.StatusBadRequest)
+		return
+	}
+
+	// Check the challenge
+	user, claims, ok := mfaChallenge(w, req.MFAToken)
+	if !ok {
+		return
+	}
+	if !user.MFAEnabled {
+		http.Error(w, "Two-factor authentication must be set up first", http.StatusBadRequest)
+		return
+	}
+
+	// Code guesses count against the same limits as password guesses
+	if !reserveLoginAttempt(w, r, user.Username) {
+		return
+	}
+
+	// Check the second factor
+	valid, err := models.VerifySecondFactor(user.ID, req.Code)
+	if err != nil {
+		releaseLoginAttempt(r, user.Username)
+		http.Error(w, "Failed to log in", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		recordLoginFailure(r, user.Username)
+		auditLoginFailed(r, user.Username, user, "second_factor")
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+	recordLoginSuccess(r, user.Username)
+	auditLogin(r, user, "password+totp")
+
+	completeMFALogin(w, user, claims, nil)
+}
+
+// MFALoginEnrollHandler starts TOTP enrollment during a login for users
+// whose organization requires MFA
+func MFALoginEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" {
+		http.Error(w, "MFA token is required", http.StatusBadRequest)
+		return
+	}
+
+	// Check the challenge
+	user, _, ok := mfaChallenge(w, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	writeEnrollment(w, user.ID)
+}
+
+// MFALoginConfirmHandler confirms a TOTP enrollment started during a login
+// and completes the login
+func MFALoginConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+		http.Error(w, "MFA token and code are required", http.StatusBadRequest)
+		return
+	}
+
+	// Check the challenge
+	user, claims, ok := mfaChallenge(w, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	// Enable TOTP
+	codes, ok := confirmEnrollment(w, r, user, req.Code)
+	if !ok {
+		return
+	}
+	user.MFAEnabled = true
+	audit(r, models.AuditEvent{
+		Action:     models.AuditMFAEnable,
+		ActorID:    user.ID,
+		OrgID:      user.OrgID,
+		TargetType: "user",
+		TargetID:   user.ID,
+	})
+	auditLogin(r, user, "password+totp")
+
+	completeMFALogin(w, user, claims, codes)
+}
+
+// BeginTOTPHandler starts TOTP enrollment for the caller
+func BeginTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	writeEnrollment(w, principal.UserID)
+}
+
+// ConfirmTOTPHandler enables TOTP for the caller
+func ConfirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Code is required", http.StatusBadRequest)
+		return
+	}
+
+	// Enable TOTP
+	user, ok := principalUser(w, principal)
+	if !ok {
+		return
+	}
+	codes, ok := confirmEnrollment(w, r, user, req.Code)
+	if !ok {
+		return
+	}
+	auditPrincipal(r, principal, models.AuditMFAEnable, "user", principal.UserID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTPHandler turns TOTP off for the caller
+func DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Code is required", http.StatusBadRequest)
+		return
+	}
+
+	// Code guesses count against the same limits as login guesses, so a
+	// stolen session cannot brute-force its way to turning MFA off
+	user, ok := principalUser(w, principal)
+	if !ok {
+		return
+	}
+	if !reserveLoginAttempt(w, r, user.Username) {
+		return
+	}
+
+	// Disable TOTP
+	err := models.DisableTOTP(user.ID, req.Code)
+	endCodeAttempt(r, user.Username, err)
+	switch {
+	case errors.Is(err, models.ErrMFANotEnrolled):
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	case errors.Is(err, models.ErrMFARequired):
+		http.Error(w, "Your organization requires two-factor authentication", http.StatusForbidden)
+		return
+	case errors.Is(err, models.ErrMFAInvalidCode):
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	auditPrincipal(r, principal, models.AuditMFADisable, "user", principal.UserID, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeEnrollment starts TOTP enrollment and sends the secret and URI
+func writeEnrollment(w http.ResponseWriter, userID string) {
+	enrollment, err := models.BeginTOTPEnrollment(userID)
+	if errors.Is(err, models.ErrMFAAlreadyEnabled) {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to start enrollment", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// principalUser loads the caller's account
+func principalUser(w http.ResponseWriter, principal *utils.Principal) (*models.User, bool) {
+	user, err := models.GetUserByID(principal.UserID)
+	if err != nil {
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return nil, false
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
+
+// endCodeAttempt settles a throttled second factor check with its outcome
+func endCodeAttempt(r *http.Request, username string, err error) {
+	switch {
+	case errors.Is(err, models.ErrMFAInvalidCode):
+		recordLoginFailure(r, username)
+	case err == nil:
+		recordLoginSuccess(r, username)
+	default:
+		releaseLoginAttempt(r, username)
+	}
+}
+
+// confirmEnrollment enables TOTP and returns the new recovery codes. Code
+// guesses count against the login throttle.
+func confirmEnrollment(w http.ResponseWriter, r *http.Request, user *models.User, code string) ([]string, bool) {
+	if !reserveLoginAttempt(w, r, user.Username) {
+		return nil, false
+	}
+	codes, err := models.ConfirmTOTPEnrollment(user.ID, code)
+	endCodeAttempt(r, user.Username, err)
+	switch {
+	case errors.Is(err, models.ErrMFAAlreadyEnabled):
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return nil, false
+	case errors.Is(err, models.ErrMFANotEnrolled):
+		http.Error(w, "Start enrollment first", http.StatusBadRequest)
+		return nil, false
+	case errors.Is(err, models.ErrMFAInvalidCode):
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return nil, false
+	case err != nil:
+		http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return nil, false
+	}
+	return codes, true
+}
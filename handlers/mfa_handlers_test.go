@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"webscrapper/database"
+	"webscrapper/models"
+	"webscrapper/utils"
+)
+
+func TestMFACodeChecksAreThrottled(t *testing.T) {
+	saved := authOptions
+	t.Cleanup(func() { ConfigureAuth(saved) })
+
+	tests := []struct {
+		name    string
+		path    string
+		handler http.HandlerFunc
+		enabled bool // TOTP is on, rather than enrollment started
+	}{
+		{"disable", "/api/me/mfa/disable", DisableTOTPHandler, true},
+		{"confirm", "/api/me/mfa/confirm", ConfirmTOTPHandler, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Three wrong codes lock the account out
+			ConfigureAuth(AuthOptions{
+				LocalLogin: true,
+				Throttle: utils.NewLoginThrottle(utils.NewMemoryAttemptStore(),
+					utils.ThrottlePolicy{MaxFailures: 3, Lockout: time.Minute, Window: time.Minute},
+					utils.DefaultIPPolicy),
+			})
+			memory := database.NewMemoryStore()
+			models.SetStore(memory)
+			user := &database.User{
+				ID:          "user-1",
+				Username:    "alice",
+				Role:        models.RoleCandidate,
+				CreatedAt:   time.Now(),
+				TOTPSecret:  "JBSWY3DPEHPK3PXP",
+				TOTPEnabled: tt.enabled,
+			}
+			if err := memory.SaveUser(user); err != nil {
+				t.Fatal(err)
+			}
+			principal := &utils.Principal{UserID: user.ID, Roles: []string{models.RoleCandidate}}
+
+			var codes []int
+			for i := 0; i < 4; i++ {
+				req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{"code":"not-a-code"}`))
+				req = req.WithContext(utils.WithPrincipal(req.Context(), principal))
+				rec := httptest.NewRecorder()
+				tt.handler(rec, req)
+				codes = append(codes, rec.Code)
+			}
+			want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}
+			for i := range want {
+				if codes[i] != want[i] {
+					t.Fatalf("statuses = %v, want %v", codes, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"webscrapper/models"
+	"webscrapper/utils"
+)
+
+// ssoHandoffTTL is how long the SPA has to collect tokens after an SSO login
+const ssoHandoffTTL = time.Minute
+
+// oidcStateCookie binds an SSO login to the browser that started it, so a
+// callback URL from someone else's login is rejected (login CSRF)
+const oidcStateCookie = "oidc_state"
+
+// oidcStateCookiePath limits the state cookie to the SSO endpoints
+const oidcStateCookiePath = "/api/oidc/"
+
+// AuthOptions are the sign-in methods enabled at startup
+type AuthOptions struct {
+	LocalLogin bool                // Username/password login and registration
+	OIDC       *utils.OIDCProvider // Single sign-on, nil when not configured
+
+	// Throttle limits failed local logins, nil disables it
+	Throttle *utils.LoginThrottle
+}
+
+// authOptions holds the options passed to ConfigureAuth
+var authOptions = AuthOptions{
+	LocalLogin: true,
+	Throttle:   utils.NewLoginThrottle(utils.NewMemoryAttemptStore(), utils.DefaultUserPolicy, utils.DefaultIPPolicy),
+}
+
+// ConfigureAuth sets the enabled sign-in methods
+func ConfigureAuth(options AuthOptions) {
+	authOptions = options
+}
+
+// LocalLoginEnabled reports whether local accounts may log in and register
+func LocalLoginEnabled() bool {
+	return authOptions.LocalLogin
+}
+
+// MFALoginEnabled reports whether logins may need the MFA step, which both
+// local and SSO logins can
+func MFALoginEnabled() bool {
+	return authOptions.LocalLogin || authOptions.OIDC != nil
+}
+
+// AuthConfigResponse tells the SPA which sign-in methods to offer
+type AuthConfigResponse struct {
+	LocalLogin bool `json:"local_login"`
+	OIDC       bool `json:"oidc"`
+}
+
+// SSOTokenRequest represents the SSO handoff request body
+type SSOTokenRequest struct {
+	Code string `json:"code"`
+}
+
+// ssoHandoff is a completed SSO login waiting for the SPA to collect it.
+// Users who need a second factor get an MFA challenge instead of tokens.
+type ssoHandoff struct {
+	response  *AuthResponse
+	mfaUser   *models.User
+	expiresAt time.Time
+}
+
+// ssoHandoffs maps one-time handoff codes to completed SSO logins, so
+// tokens never appear in a redirect URL
+var ssoHandoffs = struct {
+	sync.Mutex
+	codes map[string]*ssoHandoff
+}{codes: make(map[string]*ssoHandoff)}
+
+// AuthConfigHandler reports the enabled sign-in methods
+func AuthConfigHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuthConfigResponse{
+		LocalLogin: authOptions.LocalLogin,
+		OIDC:       authOptions.OIDC != nil,
+	})
+}
+
+// OIDCLoginHandler redirects the browser to the identity provider
+func OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if authOptions.OIDC == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	authURL, state, err := authOptions.OIDC.AuthCodeURL()
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	// Lax still sends the cookie on the IdP's top-level redirect back to us
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     oidcStateCookiePath,
+		MaxAge:   int(utils.OIDCLoginTTL / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallbackHandler completes an SSO login. It signs the user in and
+// redirects back to the SPA with a one-time code for the tokens.
+func OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if authOptions.OIDC == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The IdP reports failures such as a cancelled login as query parameters
+	query := r.URL.Query()
+	if idpError := query.Get("error"); idpError != "" {
+		http.Redirect(w, r, "/app#login?sso_error="+url.QueryEscape(idpError), http.StatusFound)
+		return
+	}
+
+	// The state must come back to the browser that started the login
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		http.Error(w, "Login expired, please try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     oidcStateCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// Redeem the authorization code
+	identity, err := authOptions.OIDC.Exchange(r.Context(), query.Get("code"), state)
+	if errors.Is(err, utils.ErrOIDCState) {
+		http.Error(w, "Login expired, please try again", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("OIDC login failed:", err)
+		http.Error(w, "Single sign-on failed", http.StatusUnauthorized)
+		return
+	}
+
+	// Find, link or provision the account
+	user, err := models.LoginWithOIDC(identity.Issuer, identity.Subject, identity.Email, identity.EmailVerified, identity.Username)
+	if errors.Is(err, models.ErrOIDCEmailUnverified) || errors.Is(err, models.ErrOIDCLocalUnverified) ||
+		errors.Is(err, models.ErrOIDCAlreadyLinked) {
+		http.Error(w, "An account with this email already exists and cannot be linked", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+
+	// Ask for a second factor before issuing tokens
+	handoff := &ssoHandoff{}
+	mfaRequired, err := models.MFARequired(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+	if mfaRequired {
+		handoff.mfaUser = user
+	} else {
+		auditLogin(r, user, "oidc")
+
+		// Generate tokens
+		handoff.response, err = issueTokens(user, "")
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+		handoff.response.User = user
+	}
+
+	// Park them behind a one-time code
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+	code := base64.RawURLEncoding.EncodeToString(raw)
+	ssoHandoffs.Lock()
+	now := time.Now()
+	for c, parked := range ssoHandoffs.codes {
+		if parked.expiresAt.Before(now) {
+			delete(ssoHandoffs.codes, c)
+		}
+	}
+	handoff.expiresAt = now.Add(ssoHandoffTTL)
+	ssoHandoffs.codes[code] = handoff
+	ssoHandoffs.Unlock()
+
+	http.Redirect(w, r, "/app#sso="+code, http.StatusFound)
+}
+
+// SSOTokenHandler exchanges a one-time SSO handoff code for the tokens, or
+// for an MFA challenge when the user needs a second factor
+func SSOTokenHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req SSOTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Code is required", http.StatusBadRequest)
+		return
+	}
+
+	// Consume the code
+	ssoHandoffs.Lock()
+	handoff, exists := ssoHandoffs.codes[req.Code]
+	delete(ssoHandoffs.codes, req.Code)
+	ssoHandoffs.Unlock()
+	if !exists || time.Now().After(handoff.expiresAt) {
+		http.Error(w, "Invalid or expired code", http.StatusUnauthorized)
+		return
+	}
+	if handoff.mfaUser != nil {
+		writeMFAChallenge(w, handoff.mfaUser)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(handoff.response)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"webscrapper/database"
+	"webscrapper/models"
+	"webscrapper/utils"
+)
+
+// withOIDC enables SSO against a stub provider whose token endpoint
+// rejects every code, for the length of the test
+func withOIDC(t *testing.T) {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			http.Error(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/jwks",
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := utils.NewOIDCProvider(context.Background(), utils.OIDCConfig{
+		Issuer:      server.URL,
+		ClientID:    "client-id",
+		RedirectURL: "http://localhost/api/oidc/callback",
+	}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := authOptions
+	ConfigureAuth(AuthOptions{LocalLogin: true, OIDC: provider})
+	t.Cleanup(func() { ConfigureAuth(saved) })
+}
+
+// startOIDCLogin runs the login handler and returns the state cookie and
+// the state sent to the IdP
+func startOIDCLogin(t *testing.T) (*http.Cookie, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	OIDCLoginHandler(rec, httptest.NewRequest(http.MethodGet, "/api/oidc/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login status = %d", rec.Code)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == oidcStateCookie {
+			return cookie, location.Query().Get("state")
+		}
+	}
+	t.Fatal("login set no state cookie")
+	return nil, ""
+}
+
+func TestOIDCLoginSetsStateCookie(t *testing.T) {
+	withOIDC(t)
+	cookie, state := startOIDCLogin(t)
+
+	if cookie.Value != state || state == "" {
+		t.Errorf("cookie value = %q, auth URL state = %q", cookie.Value, state)
+	}
+	if !cookie.HttpOnly {
+		t.Error("state cookie is not HttpOnly")
+	}
+	if cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("SameSite = %v, want Lax", cookie.SameSite)
+	}
+	if cookie.Path != oidcStateCookiePath {
+		t.Errorf("Path = %q, want %q", cookie.Path, oidcStateCookiePath)
+	}
+}
+
+func TestOIDCCallbackChecksStateCookie(t *testing.T) {
+	withOIDC(t)
+	cookie, state := startOIDCLogin(t)
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		want   int
+	}{
+		// Someone else's callback URL opened in a browser without the login
+		{"no cookie", nil, http.StatusBadRequest},
+		{"other login", &http.Cookie{Name: oidcStateCookie, Value: "other-state"}, http.StatusBadRequest},
+		// A matching cookie gets as far as redeeming the code, which the
+		// stub provider refuses
+		{"matching cookie", cookie, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/oidc/callback?code=some-code&state="+url.QueryEscape(state), nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			rec := httptest.NewRecorder()
+			OIDCCallbackHandler(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestSSOTokenAsksForSecondFactor(t *testing.T) {
+	models.SetStore(database.NewMemoryStore())
+	keyring, err := utils.LoadKeyringFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	utils.SetKeyring(keyring)
+
+	// An SSO login parked for a user who has MFA enabled
+	user := &models.User{ID: "user-1", Username: "alice", MFAEnabled: true}
+	ssoHandoffs.Lock()
+	ssoHandoffs.codes["mfa-code"] = &ssoHandoff{mfaUser: user, expiresAt: time.Now().Add(ssoHandoffTTL)}
+	ssoHandoffs.Unlock()
+
+	rec := httptest.NewRecorder()
+	SSOTokenHandler(rec, httptest.NewRequest(http.MethodPost, "/api/oidc/token", strings.NewReader(`{"code":"mfa-code"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var challenge MFAChallengeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&challenge); err != nil {
+		t.Fatal(err)
+	}
+	if !challenge.MFARequired || challenge.MFAToken == "" {
+		t.Errorf("response = %+v, want an MFA challenge", challenge)
+	}
+	if _, err := utils.ValidateMFAToken(challenge.MFAToken); err != nil {
+		t.Errorf("challenge token: %v", err)
+	}
+}
+
+func TestSSOSecondFactorWithoutLocalLogin(t *testing.T) {
+	withOIDC(t)
+	authOptions.LocalLogin = false
+	if !MFALoginEnabled() {
+		t.Fatal("MFA login step is disabled with only SSO enabled")
+	}
+
+	memory := database.NewMemoryStore()
+	models.SetStore(memory)
+	keyring, err := utils.LoadKeyringFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	utils.SetKeyring(keyring)
+	if err := memory.SaveUser(&database.User{ID: "user-1", Username: "alice", Role: models.RoleCandidate, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// An SSO user whose organization requires MFA enrolls during login
+	ssoHandoffs.Lock()
+	ssoHandoffs.codes["enroll-code"] = &ssoHandoff{mfaUser: &models.User{ID: "user-1", Username: "alice"}, expiresAt: time.Now().Add(ssoHandoffTTL)}
+	ssoHandoffs.Unlock()
+	rec := httptest.NewRecorder()
+	SSOTokenHandler(rec, httptest.NewRequest(http.MethodPost, "/api/oidc/token", strings.NewReader(`{"code":"enroll-code"}`)))
+	var challenge MFAChallengeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&challenge); err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(MFALoginRequest{MFAToken: challenge.MFAToken})
+	rec = httptest.NewRecorder()
+	MFALoginEnrollHandler(rec, httptest.NewRequest(http.MethodPost, "/api/login/mfa/enroll", strings.NewReader(string(body))))
+	if rec.Code != http.StatusOK {
+		t.Errorf("enroll status = %d: %s", rec.Code, rec.Body.String())
+	}
+}
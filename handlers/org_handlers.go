@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"webscrapper/models"
+	"webscrapper/utils"
+)
+
+// CreateOrgRequest represents the organization creation request body
+type CreateOrgRequest struct {
+	Name string `json:"name"`
+}
+
+// OrgResponse returns an organization together with fresh tokens that
+// carry the caller's new organization and role
+type OrgResponse struct {
+	Organization *models.Organization `json:"organization"`
+	*AuthResponse
+}
+
+// InvitationRequest represents the invitation request body
+type InvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// InvitationResponse returns the invitation token to send to the invitee
+type InvitationResponse struct {
+	Token      string             `json:"token"`
+	Invitation *models.Invitation `json:"invitation"`
+}
+
+// AcceptInvitationRequest represents the invitation acceptance request body
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+}
+
+// UpdateRoleRequest represents the role change request body
+type UpdateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// CreateOrgHandler creates an organization with the caller as its admin
+func CreateOrgHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req CreateOrgRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		http.Error(w, "Organization name is required", http.StatusBadRequest)
+		return
+	}
+
+	// Create the organization
+	org, err := models.CreateOrganization(req.Name, principal.UserID)
+	if errors.Is(err, models.ErrAlreadyInOrg) {
+		http.Error(w, "You already belong to an organization", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create organization", http.StatusInternalServerError)
+		return
+	}
+
+	// Issue tokens for the new membership in the same login session
+	user, err := models.GetUserByID(principal.UserID)
+	if err != nil || user == nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	tokens, err := issueTokens(user, principal.FamilyID)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	tokens.User = user
+	audit(r, models.AuditEvent{
+		Action:     models.AuditOrgCreate,
+		ActorID:    principal.UserID,
+		OrgID:      org.ID,
+		TargetType: "organization",
+		TargetID:   org.ID,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(OrgResponse{Organization: org, AuthResponse: tokens})
+}
+
+// GetCurrentOrgHandler returns the caller's organization
+func GetCurrentOrgHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !authorize(principal, ActionViewOrg, Resource{OrgID: principal.OrgID}) {
+		http.Error(w, "You do not belong to an organization", http.StatusNotFound)
+		return
+	}
+
+	// Get the organization
+	org, err := models.GetOrganizationByID(principal.OrgID)
+	if err != nil || org == nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(org)
+}
+
+// UpdateOrgSettingsHandler replaces the settings of the caller's organization
+func UpdateOrgSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !authorize(principal, ActionManageOrg, Resource{OrgID: principal.OrgID}) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Parse request body
+	var settings models.OrgSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if settings.RetentionDays < 0 {
+		http.Error(w, "Retention days must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	// Update the settings
+	org, err := models.UpdateOrgSettings(principal.OrgID, settings)
+	if errors.Is(err, models.ErrOrgNotFound) {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update organization", http.StatusInternalServerError)
+		return
+	}
+	auditPrincipal(r, principal, models.AuditOrgSettings, "organization", principal.OrgID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(org)
+}
+
+// CreateInvitationHandler invites an email address to the caller's organization
+func CreateInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !authorize(principal, ActionManageUsers, Resource{OrgID: principal.OrgID}) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Parse request body
+	var req InvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Email) == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleCandidate
+	}
+
+	// Create the invitation
+	token, invitation, err := models.CreateInvitation(principal.OrgID, req.Email, req.Role, principal.UserID)
+	if errors.Is(err, models.ErrInvalidRole) {
+		http.Error(w, "Role must be candidate, recruiter or admin", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+	auditPrincipal(r, principal, models.AuditInvitation, "email", invitation.Email, map[string]string{
+		"role": invitation.Role,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(InvitationResponse{Token: token, Invitation: invitation})
+}
+
+// AcceptInvitationHandler joins the caller to the organization of an invitation
+func AcceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invitation token is required", http.StatusBadRequest)
+		return
+	}
+
+	// Accept the invitation
+	user, err := models.AcceptInvitation(req.Token, principal.UserID)
+	switch {
+	case errors.Is(err, models.ErrInvalidInvitation):
+		http.Error(w, "Invalid or expired invitation", http.StatusBadRequest)
+		return
+	case errors.Is(err, models.ErrInvitationEmail):
+		http.Error(w, "This invitation was sent to a different email address", http.StatusForbidden)
+		return
+	case errors.Is(err, models.ErrAlreadyInOrg):
+		http.Error(w, "You already belong to another organization", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to accept invitation", http.StatusInternalServerError)
+		return
+	}
+	audit(r, models.AuditEvent{
+		Action:     models.AuditJoinOrg,
+		ActorID:    user.ID,
+		OrgID:      user.OrgID,
+		TargetType: "organization",
+		TargetID:   user.OrgID,
+		Details:    map[string]string{"role": user.Role},
+	})
+
+	// Issue tokens for the new membership in the same login session
+	response, err := issueTokens(user, principal.FamilyID)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	response.User = user
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListMembersHandler lists the users of the caller's organization
+func ListMembersHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !authorize(principal, ActionManageUsers, Resource{OrgID: principal.OrgID}) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Get users in the organization
+	users, err := models.GetUsersByOrgID(principal.OrgID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve users", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// memberFromRequest loads the member named in the URL and checks the caller
+// may manage them. Admins cannot change their own membership so an
+// organization always keeps an admin.
+func memberFromRequest(w http.ResponseWriter, r *http.Request, principal *utils.Principal) (*models.User, bool) {
+	user, err := models.GetUserByID(chi.URLParam(r, "id"))
+	if err != nil || user == nil || user.OrgID != principal.OrgID {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !authorize(principal, ActionManageUsers, userResource(user)) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	if user.ID == principal.UserID {
+		http.Error(w, "You cannot change your own membership", http.StatusBadRequest)
+		return nil, false
+	}
+	return user, true
+}
+
+// UpdateMemberRoleHandler changes the role of a user in the caller's organization
+func UpdateMemberRoleHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Get the target user
+	user, ok := memberFromRequest(w, r, principal)
+	if !ok {
+		return
+	}
+
+	// Update the role
+	previousRole := user.Role
+	user, err := models.UpdateUserRole(user.ID, req.Role)
+	if errors.Is(err, models.ErrInvalidRole) {
+		http.Error(w, "Role must be candidate, recruiter or admin", http.StatusBadRequest)
+		return
+	}
+	if err != nil || user == nil {
+		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		return
+	}
+	auditPrincipal(r, principal, models.AuditMemberRole, "user", user.ID, map[string]string{
+		"from": previousRole,
+		"to":   user.Role,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// RemoveMemberHandler removes a user from the caller's organization.
+// The user keeps their account and takes their resumes along.
+func RemoveMemberHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get the target user
+	user, ok := memberFromRequest(w, r, principal)
+	if !ok {
+		return
+	}
+
+	// Remove the membership
+	if err := models.RemoveMember(principal.OrgID, user.ID); err != nil {
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+	auditPrincipal(r, principal, models.AuditMemberRemove, "user", user.ID, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"webscrapper/models"
+	"webscrapper/utils"
+)
+
+// Action is something a principal may attempt; see authorize
+type Action string
+
+// Actions checked by authorize
+const (
+	ActionUploadResume   Action = "resume:upload"
+	ActionListResumes    Action = "resume:list"
+	ActionViewResume     Action = "resume:view"
+	ActionEditResume     Action = "resume:edit"
+	ActionDeleteResume   Action = "resume:delete"
+	ActionListOrgResumes Action = "org:resumes:list"
+	ActionViewJob        Action = "job:view"
+	ActionManageJob      Action = "job:manage"
+	ActionViewOrg        Action = "org:view"
+	ActionManageOrg      Action = "org:manage"
+	ActionManageUsers    Action = "users:manage"
+	ActionManageAPIKey   Action = "apikey:manage"
+	ActionViewAudit      Action = "audit:view"
+)
+
+// Resource describes who owns the object an action targets
+type Resource struct {
+	OwnerID string
+	OrgID   string
+}
+
+// resumeResource describes a resume for authorize
+func resumeResource(resume *models.Resume) Resource {
+	return Resource{OwnerID: resume.UserID, OrgID: resume.OrgID}
+}
+
+// jobResource describes a job for authorize
+func jobResource(job *models.Job) Resource {
+	return Resource{OwnerID: job.OwnerID, OrgID: job.OrgID}
+}
+
+// userResource describes a user account for authorize
+func userResource(user *models.User) Resource {
+	return Resource{OwnerID: user.ID, OrgID: user.OrgID}
+}
+
+// authorize is the single policy check used by every handler.
+// Owners may always act on their own resources; recruiters may read and
+// curate resumes owned by their organization; members see their
+// organization's jobs and recruiters curate them; admins manage their
+// organization.
+func authorize(principal *utils.Principal, action Action, resource Resource) bool {
+	sameOrg := resource.OrgID != "" && resource.OrgID == principal.OrgID
+
+	switch action {
+	case ActionUploadResume:
+		return true
+	case ActionListResumes:
+		return resource.OwnerID == principal.UserID
+	case ActionViewResume, ActionEditResume, ActionDeleteResume:
+		if resource.OwnerID == principal.UserID {
+			return true
+		}
+		return sameOrg && (principal.HasRole(models.RoleRecruiter) || principal.HasRole(models.RoleAdmin))
+	case ActionListOrgResumes:
+		return principal.OrgID != "" && (principal.HasRole(models.RoleRecruiter) || principal.HasRole(models.RoleAdmin))
+	case ActionViewJob:
+		return resource.OwnerID == principal.UserID || sameOrg
+	case ActionManageJob:
+		if resource.OwnerID == principal.UserID {
+			return true
+		}
+		return sameOrg && (principal.HasRole(models.RoleRecruiter) || principal.HasRole(models.RoleAdmin))
+	case ActionViewOrg:
+		return sameOrg
+	case ActionManageOrg, ActionManageUsers, ActionViewAudit:
+		return sameOrg && principal.HasRole(models.RoleAdmin)
+	case ActionManageAPIKey:
+		if resource.OwnerID == principal.UserID {
+			return true
+		}
+		return sameOrg && principal.HasRole(models.RoleAdmin)
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"webscrapper/models"
+	"webscrapper/utils"
+)
+
+// UpdateProfileRequest represents the profile update request body. Changing
+// the email or password requires the current password.
+type UpdateProfileRequest struct {
+	Email           *string `json:"email"`
+	DisplayName     *string `json:"display_name"`
+	CurrentPassword string  `json:"current_password"`
+	NewPassword     *string `json:"new_password"`
+}
+
+// DeleteAccountRequest represents the account deletion request body
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// ProfileResponse returns the caller's profile
+type ProfileResponse struct {
+	User *models.User `json:"user"`
+
+	// Set when the password changed, since that ends every other session
+	Tokens *AuthResponse `json:"tokens,omitempty"`
+}
+
+// confirmPassword checks the caller's current password before a sensitive
+// change. Wrong guesses count against the login throttle. Accounts without
+// a password pass unless allowNone is false.
+func confirmPassword(w http.ResponseWriter, r *http.Request, user *models.User, password string, allowNone bool) bool {
+	if !reserveLoginAttempt(w, r, user.Username) {
+		return false
+	}
+	err := models.CheckPassword(user.ID, password)
+	if !errors.Is(err, models.ErrIncorrectPassword) {
+		releaseLoginAttempt(r, user.Username)
+	}
+	switch {
+	case errors.Is(err, models.ErrNoPassword):
+		if !allowNone {
+			http.Error(w, "Your account signs in with single sign-on and has no password", http.StatusBadRequest)
+			return false
+		}
+		return true
+	case errors.Is(err, models.ErrIncorrectPassword):
+		recordLoginFailure(r, user.Username)
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return false
+	case err != nil:
+		http.Error(w, "Failed to check password", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// GetProfileHandler returns the caller's own account
+func GetProfileHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := models.GetUserByID(principal.UserID)
+	if err != nil {
+		http.Error(w, "Failed to load profile", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ProfileResponse{User: user})
+}
+
+// UpdateProfileHandler changes the caller's email, display name or password
+func UpdateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.NewPassword != nil && (*req.NewPassword == "" || len(*req.NewPassword) > models.MaxPasswordLength) {
+		http.Error(w, "New password must be between 1 and 72 bytes", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUserByID(principal.UserID)
+	if err != nil || user == nil {
+		http.Error(w, "Failed to load profile", http.StatusInternalServerError)
+		return
+	}
+
+	// Email and password changes need the current password
+	if req.Email != nil || req.NewPassword != nil {
+		if !confirmPassword(w, r, user, req.CurrentPassword, req.NewPassword == nil) {
+			return
+		}
+	}
+
+	// Apply the changes
+	updated, err := models.UpdateProfile(user.ID, models.ProfileUpdate{
+		Email:       req.Email,
+		DisplayName: req.DisplayName,
+		NewPassword: req.NewPassword,
+	})
+	var conflict *models.ConflictError
+	switch {
+	case errors.As(err, &conflict):
+		writeError(w, http.StatusConflict, ErrorResponse{
+			Error:   "conflict",
+			Message: conflict.Error(),
+			Field:   conflict.Field,
+		})
+		return
+	case errors.Is(err, models.ErrEmailRequired), errors.Is(err, models.ErrDisplayNameLength):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+		return
+	}
+	response := ProfileResponse{User: updated}
+
+	// Record what changed, but not the values
+	var changed []string
+	if req.Email != nil && user.Email != updated.Email {
+		changed = append(changed, "email")
+	}
+	if req.DisplayName != nil {
+		changed = append(changed, "display_name")
+	}
+	if len(changed) > 0 {
+		auditPrincipal(r, principal, models.AuditProfileUpdate, "user", user.ID, map[string]string{
+			"fields": strings.Join(changed, " "),
+		})
+	}
+	if req.NewPassword != nil {
+		auditPrincipal(r, principal, models.AuditPasswordChange, "user", user.ID, nil)
+	}
+
+	// Ask the user to confirm a new address
+	if user.Email != updated.Email && !updated.EmailVerified {
+		go func() {
+			if err := sendEmailVerification(updated); err != nil {
+				log.Printf("Error sending verification email to user %s: %v", updated.ID, err)
+			}
+		}()
+	}
+
+	// A new password ends every session, so start a fresh one for the caller
+	if req.NewPassword != nil {
+		if principal.FamilyID != "" {
+			if err := models.RevokeTokenFamily(principal.FamilyID); err != nil {
+				log.Println("Error revoking session after password change:", err)
+			}
+		}
+		if err := models.RevokeAccessToken(principal.TokenID, principal.ExpiresAt); err != nil {
+			log.Println("Error revoking access token after password change:", err)
+		}
+		response.Tokens, err = issueTokens(updated, "")
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteProfileHandler deletes the caller's account, resumes and uploaded
+// files and returns a receipt of what was removed
+func DeleteProfileHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUserByID(principal.UserID)
+	if err != nil || user == nil {
+		http.Error(w, "Failed to load profile", http.StatusInternalServerError)
+		return
+	}
+
+	// Confirm with the password when the account has one
+	if !confirmPassword(w, r, user, req.Password, true) {
+		return
+	}
+
+	// Delete everything
+	receipt, err := models.DeleteAccount(user.ID)
+	if errors.Is(err, models.ErrLastAdmin) {
+		http.Error(w, "Make another member an admin before deleting your account", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting account %s: %v", user.ID, err)
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	// End the session
+	if principal.FamilyID != "" {
+		if err := models.RevokeTokenFamily(principal.FamilyID); err != nil {
+			log.Println("Error revoking session of deleted account:", err)
+		}
+	}
+	if err := models.RevokeAccessToken(principal.TokenID, principal.ExpiresAt); err != nil {
+		log.Println("Error revoking access token of deleted account:", err)
+	}
+	auditPrincipal(r, principal, models.AuditAccountDelete, "user", receipt.UserID, map[string]string{
+		"username":         receipt.Username,
+		"resumes_deleted":  strconv.Itoa(receipt.ResumesDeleted),
+		"files_deleted":    strconv.Itoa(receipt.FilesDeleted),
+		"api_keys_revoked": strconv.Itoa(receipt.APIKeysRevoked),
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/ledongthuc/pdf"
 	"webscrapper/models"
@@ -32,12 +36,20 @@ func UploadResumeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user ID from request header (set by auth middleware)
-	userID := r.Header.Get("X-User-ID")
-	if userID == "" {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	userID := principal.UserID
+	if !authorize(principal, ActionUploadResume, Resource{OwnerID: userID, OrgID: principal.OrgID}) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if !requireVerifiedEmail(w, userID) {
+		return
+	}
 
 	// Parse multipart form
 	err := r.ParseMultipartForm(10 << 20) // 10 MB max
@@ -110,15 +122,18 @@ func UploadResumeHandler(w http.ResponseWriter, r *http.Request) {
 		text += content
 	}
 
-	// Analyze resume text
-	keywords, skills, education, experience := utils.AnalyzeResume(text)
+	// Analyze resume text with the organization's skill dictionary
+	keywords, skills, education, experience := utils.AnalyzeResumeWithSkills(text, models.SkillDictionary(principal.OrgID))
 
 	// Save resume to database
-	resume, err := models.SaveResume(userID, header.Filename, text, keywords, skills, education, experience)
+	resume, err := models.SaveResume(userID, principal.OrgID, header.Filename, filepath, text, keywords, skills, education, experience)
 	if err != nil {
 		http.Error(w, "Failed to save resume data", http.StatusInternalServerError)
 		return
 	}
+	auditPrincipal(r, principal, models.AuditResumeUpload, "resume", resume.ID, map[string]string{
+		"filename": header.Filename,
+	})
 
 	// Create response
 	response := ResumeUploadResponse{
@@ -134,7 +149,20 @@ func UploadResumeHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetResumesHandler retrieves all resumes for a user
+// UpdateResumeRequest represents the resume update request body; omitted
+// fields are kept
+type UpdateResumeRequest struct {
+	Title         *string   `json:"title"`
+	CandidateName *string   `json:"candidate_name"`
+	Tags          *[]string `json:"tags"`
+}
+
+// SkillGapRequest represents the skill gap request body
+type SkillGapRequest struct {
+	JobText string `json:"job_text"`
+}
+
+// GetResumesHandler lists a user's resumes a page at a time
 func GetResumesHandler(w http.ResponseWriter, r *http.Request) {
 	// Only allow GET method
 	if r.Method != http.MethodGet {
@@ -142,23 +170,27 @@ func GetResumesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user ID from request header (set by auth middleware)
-	userID := r.Header.Get("X-User-ID")
-	if userID == "" {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	userID := principal.UserID
+	if !authorize(principal, ActionListResumes, Resource{OwnerID: userID}) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
 
 	// Get resumes for user
-	resumes, err := models.GetResumesByUserID(userID)
+	resumes, err := models.GetResumesByUserID(principal.OrgID, userID)
 	if err != nil {
 		http.Error(w, "Failed to retrieve resumes", http.StatusInternalServerError)
 		return
 	}
 
-	// Send response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resumes)
+	// Send the requested page, see parseResumeQuery
+	writeResumeList(w, r, resumes)
 }
 
 // GetResumeHandler retrieves a specific resume
@@ -169,9 +201,9 @@ func GetResumeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user ID from request header (set by auth middleware)
-	userID := r.Header.Get("X-User-ID")
-	if userID == "" {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -183,20 +215,283 @@ func GetResumeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get resume
-	resume, err := models.GetResumeByID(resumeID)
-	if err != nil {
+	// Get resume (only resumes of the caller's organization are visible)
+	resume, err := models.GetResumeByID(principal.OrgID, resumeID)
+	if err != nil || resume == nil {
 		http.Error(w, "Resume not found", http.StatusNotFound)
 		return
 	}
 
-	// Check if resume belongs to user
-	if resume.UserID != userID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// Check the caller may see the resume
+	if !authorize(principal, ActionViewResume, resumeResource(resume)) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
+	// Viewing someone else's resume is audited
+	if resume.UserID != principal.UserID {
+		auditPrincipal(r, principal, models.AuditResumeView, "resume", resume.ID, map[string]string{
+			"owner_id": resume.UserID,
+		})
+	}
+
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resume)
 }
+
+// GetOrgResumesHandler retrieves every resume owned by the caller's organization
+func GetOrgResumesHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !authorize(principal, ActionListOrgResumes, Resource{OrgID: principal.OrgID}) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Get resumes for the organization
+	resumes, err := models.GetResumesByOrgID(principal.OrgID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve resumes", http.StatusInternalServerError)
+		return
+	}
+	auditPrincipal(r, principal, models.AuditResumeListOrg, "organization", principal.OrgID, map[string]string{
+		"count": strconv.Itoa(len(resumes)),
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resumes)
+}
+
+// SearchResumesHandler runs a full-text search over the caller's resumes,
+// or their organization's with scope=org. See models.SearchResumes for the
+// query syntax.
+func SearchResumesHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	params := r.URL.Query()
+
+	// Choose whose resumes to search
+	userID := principal.UserID
+	switch params.Get("scope") {
+	case "", "mine":
+	case "org":
+		if !authorize(principal, ActionListOrgResumes, Resource{OrgID: principal.OrgID}) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		userID = ""
+	default:
+		http.Error(w, "scope must be mine or org", http.StatusBadRequest)
+		return
+	}
+
+	// Paging; the cursor is the offset of the next result
+	limit, offset := defaultSearchLimit, 0
+	var err error
+	if value := params.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := params.Get("cursor"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Search
+	results, err := models.SearchResumes(principal.OrgID, userID, params.Get("q"), offset, limit)
+	var queryErr *models.QueryError
+	if errors.As(err, &queryErr) {
+		http.Error(w, queryErr.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("Error searching resumes:", err)
+		http.Error(w, "Failed to search resumes", http.StatusInternalServerError)
+		return
+	}
+	if userID == "" {
+		auditPrincipal(r, principal, models.AuditResumeSearch, "organization", principal.OrgID, map[string]string{
+			"query": params.Get("q"),
+			"total": strconv.Itoa(results.Total),
+		})
+	}
+
+	response := ResumeSearchResponse{Total: results.Total, Results: results.Hits}
+	if offset+limit < results.Total {
+		response.NextCursor = strconv.Itoa(offset + limit)
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SkillGapHandler compares a resume with a pasted job posting: the skills
+// the posting asks for, which the resume has and lacks, and how many of the
+// posting's keywords it covers
+func SkillGapHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req SkillGapRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.JobText) == "" {
+		http.Error(w, "job_text is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.JobText) > models.MaxJobDescriptionLength {
+		http.Error(w, "job_text is too long", http.StatusBadRequest)
+		return
+	}
+
+	resume := resumeForAction(w, principal, chi.URLParam(r, "id"), ActionViewResume)
+	if resume == nil {
+		return
+	}
+
+	// Viewing someone else's resume is audited
+	if resume.UserID != principal.UserID {
+		auditPrincipal(r, principal, models.AuditResumeView, "resume", resume.ID, map[string]string{
+			"owner_id": resume.UserID,
+			"report":   "skill_gap",
+		})
+	}
+
+	// Compare with the organization's skill dictionary included
+	report := utils.SkillGap(req.JobText, resume.Content, resume.Skills, models.SkillDictionary(resume.OrgID))
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// resumeForAction loads the resume named in the URL and checks the caller
+// may perform action on it. It writes the error response and returns nil
+// otherwise.
+func resumeForAction(w http.ResponseWriter, principal *utils.Principal, resumeID string, action Action) *models.Resume {
+	// Get resume (only resumes of the caller's organization are visible)
+	resume, err := models.GetResumeByID(principal.OrgID, resumeID)
+	if err != nil || resume == nil {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return nil
+	}
+
+	// Check the caller may act on the resume
+	if !authorize(principal, action, resumeResource(resume)) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil
+	}
+	return resume
+}
+
+// UpdateResumeHandler changes a resume's title, candidate name or tags
+func UpdateResumeHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req UpdateResumeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	resume := resumeForAction(w, principal, chi.URLParam(r, "id"), ActionEditResume)
+	if resume == nil {
+		return
+	}
+
+	// Apply the changes
+	updated, err := models.UpdateResume(resume.OrgID, resume.ID, models.ResumeUpdate{
+		Title:         req.Title,
+		CandidateName: req.CandidateName,
+		Tags:          req.Tags,
+	})
+	switch {
+	case errors.Is(err, models.ErrResumeTitleLength), errors.Is(err, models.ErrCandidateNameLength),
+		errors.Is(err, models.ErrTagLength), errors.Is(err, models.ErrTooManyTags):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, "Failed to update resume", http.StatusInternalServerError)
+		return
+	case updated == nil:
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+
+	// Record which fields changed
+	var changed []string
+	if req.Title != nil {
+		changed = append(changed, "title")
+	}
+	if req.CandidateName != nil {
+		changed = append(changed, "candidate_name")
+	}
+	if req.Tags != nil {
+		changed = append(changed, "tags")
+	}
+	auditPrincipal(r, principal, models.AuditResumeUpdate, "resume", resume.ID, map[string]string{
+		"owner_id": resume.UserID,
+		"fields":   strings.Join(changed, " "),
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteResumeHandler deletes a resume and its uploaded file
+func DeleteResumeHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	resume := resumeForAction(w, principal, chi.URLParam(r, "id"), ActionDeleteResume)
+	if resume == nil {
+		return
+	}
+
+	// Delete the record and the uploaded file
+	if err := models.DeleteResume(resume.OrgID, resume.ID); err != nil {
+		http.Error(w, "Failed to delete resume", http.StatusInternalServerError)
+		return
+	}
+	auditPrincipal(r, principal, models.AuditResumeDelete, "resume", resume.ID, map[string]string{
+		"owner_id": resume.UserID,
+		"filename": resume.Filename,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"webscrapper/models"
+)
+
+// Resume list page sizes
+const (
+	defaultResumeLimit = 50
+	maxResumeLimit     = 200
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// resumeFields are the fields the fields parameter may select. score is
+// only set when the query names skills.
+var resumeFields = map[string]bool{
+	"id": true, "user_id": true, "org_id": true, "filename": true, "content": true,
+	"keywords": true, "skills": true, "education": true, "experience": true,
+	"uploaded_at": true, "title": true, "candidate_name": true, "tags": true,
+	"score": true,
+}
+
+// ResumeListResponse is a page of resumes holding the requested fields.
+// Pass NextCursor as the cursor parameter to get the following page.
+type ResumeListResponse struct {
+	Resumes    []map[string]json.RawMessage `json:"resumes"`
+	NextCursor string                       `json:"next_cursor,omitempty"`
+}
+
+// ResumeSearchResponse is a page of search results, best first. Pass
+// NextCursor as the cursor parameter to get the following page.
+type ResumeSearchResponse struct {
+	Total      int                 `json:"total"`
+	Results    []*models.SearchHit `json:"results"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// parseResumeQuery reads the list parameters:
+//
+//	skill=go&skill=docker  resumes with all these skills (match=any: at least one)
+//	tag=backend            resumes with all these tags
+//	uploaded_after, uploaded_before  RFC 3339 times or YYYY-MM-DD dates
+//	sort=uploaded_at|filename|score, order=asc|desc
+//	limit, cursor          paging
+func parseResumeQuery(r *http.Request) (models.ResumeQuery, error) {
+	params := r.URL.Query()
+	query := models.ResumeQuery{
+		Skills: params["skill"],
+		Tags:   params["tag"],
+		Limit:  defaultResumeLimit,
+	}
+
+	// Filters
+	switch params.Get("match") {
+	case "", "all":
+	case "any":
+		query.MatchAnySkill = true
+	default:
+		return query, errors.New("match must be all or any")
+	}
+	var err error
+	if value := params.Get("uploaded_after"); value != "" {
+		if query.UploadedAfter, err = parseQueryTime(value); err != nil {
+			return query, errors.New("uploaded_after must be an RFC 3339 time or a YYYY-MM-DD date")
+		}
+	}
+	if value := params.Get("uploaded_before"); value != "" {
+		if query.UploadedBefore, err = parseQueryTime(value); err != nil {
+			return query, errors.New("uploaded_before must be an RFC 3339 time or a YYYY-MM-DD date")
+		}
+	}
+
+	// Order; newest first and best score first unless asked otherwise
+	query.Sort = params.Get("sort")
+	switch query.Sort {
+	case "":
+		query.Sort = models.SortUploadedAt
+		query.Descending = true
+	case models.SortUploadedAt, models.SortScore:
+		query.Descending = true
+	case models.SortFilename:
+	default:
+		return query, errors.New("sort must be uploaded_at, filename or score")
+	}
+	if query.Sort == models.SortScore && len(query.Skills) == 0 {
+		return query, errors.New("sort=score needs at least one skill parameter")
+	}
+	switch params.Get("order") {
+	case "":
+	case "asc":
+		query.Descending = false
+	case "desc":
+		query.Descending = true
+	default:
+		return query, errors.New("order must be asc or desc")
+	}
+
+	// Paging
+	if value := params.Get("limit"); value != "" {
+		query.Limit, err = strconv.Atoi(value)
+		if err != nil || query.Limit < 1 || query.Limit > maxResumeLimit {
+			return query, errors.New("limit must be between 1 and 200")
+		}
+	}
+	if value := params.Get("cursor"); value != "" {
+		query.After, err = decodeResumeCursor(value)
+		if err != nil || query.After.Sort != query.Sort || query.After.Descending != query.Descending {
+			return query, errors.New("invalid cursor")
+		}
+	}
+	return query, nil
+}
+
+// parseResumeFields reads the fields parameter. Without it every field
+// but the extracted text is returned.
+func parseResumeFields(r *http.Request) (map[string]bool, error) {
+	value := r.URL.Query().Get("fields")
+	if value == "" {
+		fields := make(map[string]bool)
+		for field := range resumeFields {
+			fields[field] = field != "content"
+		}
+		return fields, nil
+	}
+	fields := map[string]bool{"id": true}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if !resumeFields[field] {
+			return nil, errors.New("unknown field " + strconv.Quote(field))
+		}
+		fields[field] = true
+	}
+	return fields, nil
+}
+
+// projectResume returns the chosen fields of a resume
+func projectResume(resume *models.ScoredResume, fields map[string]bool, scored bool) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(resume.Resume)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	if scored {
+		all["score"] = json.RawMessage(strconv.FormatFloat(resume.Score, 'f', -1, 64))
+	}
+
+	projected := make(map[string]json.RawMessage)
+	for field, value := range all {
+		if fields[field] {
+			projected[field] = value
+		}
+	}
+	return projected, nil
+}
+
+// parseQueryTime accepts an RFC 3339 time or a YYYY-MM-DD date (UTC midnight)
+func parseQueryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// encodeResumeCursor makes an opaque cursor parameter
+func encodeResumeCursor(cursor *models.ResumeCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeResumeCursor reads a cursor made by encodeResumeCursor
+func decodeResumeCursor(value string) (*models.ResumeCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor models.ResumeCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// writeResumeList sends one page of resumes filtered, sorted and projected
+// as the request asks
+func writeResumeList(w http.ResponseWriter, r *http.Request, resumes []*models.Resume) {
+	query, err := parseResumeQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields, err := parseResumeFields(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, next := models.QueryResumes(resumes, query)
+	response := ResumeListResponse{Resumes: []map[string]json.RawMessage{}}
+	for _, resume := range page {
+		projected, err := projectResume(resume, fields, len(query.Skills) > 0)
+		if err != nil {
+			http.Error(w, "Failed to encode resumes", http.StatusInternalServerError)
+			return
+		}
+		response.Resumes = append(response.Resumes, projected)
+	}
+	if next != nil {
+		response.NextCursor = encodeResumeCursor(next)
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
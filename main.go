@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -15,9 +20,18 @@ import (
 	
 	"webscrapper/database"
 	"webscrapper/handlers"
+	"webscrapper/models"
 	"webscrapper/utils"
 )
 
+// getEnv returns the value of an environment variable or a fallback
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
 // legacyFormHandler is the original form handler kept for backward compatibility
 func legacyFormHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -115,10 +129,11 @@ func setupRoutes() http.Handler {
 	// Middleware
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.Use(utils.StripIdentityHeaders)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300,
@@ -135,8 +150,29 @@ func setupRoutes() http.Handler {
 		r.Post("/form", legacyFormHandler)
 		
 		// Auth routes
-		r.Post("/api/login", handlers.LoginHandler)
-		r.Post("/api/register", handlers.RegisterHandler)
+		r.Get("/api/auth/config", handlers.AuthConfigHandler)
+		if handlers.LocalLoginEnabled() {
+			r.Post("/api/login", handlers.LoginHandler)
+			r.Post("/api/register", handlers.RegisterHandler)
+			
+			// Password recovery by email
+			r.Post("/api/password/forgot", handlers.ForgotPasswordHandler)
+			r.Post("/api/password/reset", handlers.ResetPasswordHandler)
+		}
+		if handlers.MFALoginEnabled() {
+			// Second login step when two-factor authentication applies,
+			// after a password or an SSO login
+			r.Post("/api/login/mfa", handlers.MFALoginHandler)
+			r.Post("/api/login/mfa/enroll", handlers.MFALoginEnrollHandler)
+			r.Post("/api/login/mfa/enroll/confirm", handlers.MFALoginConfirmHandler)
+		}
+		r.Post("/api/token/refresh", handlers.RefreshHandler)
+		r.Post("/api/email/verify", handlers.VerifyEmailHandler)
+		
+		// Single sign-on routes
+		r.Get("/api/oidc/login", handlers.OIDCLoginHandler)
+		r.Get("/api/oidc/callback", handlers.OIDCCallbackHandler)
+		r.Post("/api/oidc/token", handlers.SSOTokenHandler)
 	})
 	
 	// Protected routes (require authentication)
@@ -144,10 +180,79 @@ func setupRoutes() http.Handler {
 		// Apply auth middleware
 		r.Use(utils.AuthMiddleware)
 		
-		// Resume routes
-		r.Post("/api/resumes/upload", handlers.UploadResumeHandler)
-		r.Get("/api/resumes", handlers.GetResumesHandler)
-		r.Get("/api/resumes/{id}", handlers.GetResumeHandler)
+		// Resume routes (also open to API keys holding the matching scope)
+		r.With(utils.RequireScope(models.ScopeResumesWrite)).Post("/api/resumes/upload", handlers.UploadResumeHandler)
+		r.With(utils.RequireScope(models.ScopeResumesRead)).Get("/api/resumes", handlers.GetResumesHandler)
+		r.With(utils.RequireScope(models.ScopeResumesRead)).Get("/api/resumes/search", handlers.SearchResumesHandler)
+		r.With(utils.RequireScope(models.ScopeResumesRead)).Get("/api/resumes/{id}", handlers.GetResumeHandler)
+		r.With(utils.RequireScope(models.ScopeResumesWrite)).Patch("/api/resumes/{id}", handlers.UpdateResumeHandler)
+		r.With(utils.RequireScope(models.ScopeResumesWrite)).Delete("/api/resumes/{id}", handlers.DeleteResumeHandler)
+		r.With(utils.RequireScope(models.ScopeResumesRead)).Post("/api/resumes/{id}/gap", handlers.SkillGapHandler)
+		
+		// Job routes
+		r.With(utils.RequireScope(models.ScopeJobsWrite)).Post("/api/jobs", handlers.CreateJobHandler)
+		r.With(utils.RequireScope(models.ScopeJobsRead)).Get("/api/jobs", handlers.GetJobsHandler)
+		r.With(utils.RequireScope(models.ScopeJobsRead)).Get("/api/jobs/{id}", handlers.GetJobHandler)
+		r.With(utils.RequireScope(models.ScopeJobsWrite)).Patch("/api/jobs/{id}", handlers.UpdateJobHandler)
+		r.With(utils.RequireScope(models.ScopeJobsWrite)).Delete("/api/jobs/{id}", handlers.DeleteJobHandler)
+		r.With(utils.RequireScope(models.ScopeJobsRead), utils.RequireScope(models.ScopeResumesRead)).Get("/api/jobs/{id}/matches", handlers.JobMatchesHandler)
+		
+		// Recruiter routes
+		r.Group(func(r chi.Router) {
+			r.Use(utils.RequireRole(models.RoleRecruiter, models.RoleAdmin))
+			
+			r.With(utils.RequireScope(models.ScopeResumesRead)).Get("/api/org/resumes", handlers.GetOrgResumesHandler)
+		})
+		
+		// Account routes (interactive sessions only)
+		r.Group(func(r chi.Router) {
+			r.Use(utils.RequireSession)
+			
+			r.Post("/api/logout", handlers.LogoutHandler)
+			r.Post("/api/email/verify/resend", handlers.ResendVerificationHandler)
+			
+			// Profile routes
+			r.Get("/api/me", handlers.GetProfileHandler)
+			r.Patch("/api/me", handlers.UpdateProfileHandler)
+			r.Delete("/api/me", handlers.DeleteProfileHandler)
+			
+			// Two-factor authentication routes
+			r.Post("/api/mfa/totp", handlers.BeginTOTPHandler)
+			r.Post("/api/mfa/totp/confirm", handlers.ConfirmTOTPHandler)
+			r.Delete("/api/mfa/totp", handlers.DisableTOTPHandler)
+			
+			// API key routes
+			r.Post("/api/keys", handlers.CreateAPIKeyHandler)
+			r.Get("/api/keys", handlers.ListAPIKeysHandler)
+			r.Delete("/api/keys/{id}", handlers.RevokeAPIKeyHandler)
+			
+			// Organization routes
+			r.Post("/api/orgs", handlers.CreateOrgHandler)
+			r.Get("/api/orgs/current", handlers.GetCurrentOrgHandler)
+			r.Post("/api/invitations/accept", handlers.AcceptInvitationHandler)
+			
+			// Organization management routes
+			r.Group(func(r chi.Router) {
+				r.Use(utils.RequireRole(models.RoleAdmin))
+				
+				r.Patch("/api/orgs/current/settings", handlers.UpdateOrgSettingsHandler)
+				r.Post("/api/orgs/current/invitations", handlers.CreateInvitationHandler)
+				r.Get("/api/orgs/current/members", handlers.ListMembersHandler)
+				r.Patch("/api/orgs/current/members/{id}/role", handlers.UpdateMemberRoleHandler)
+				r.Delete("/api/orgs/current/members/{id}", handlers.RemoveMemberHandler)
+				r.Get("/api/orgs/current/keys", handlers.ListOrgAPIKeysHandler)
+				r.Get("/api/audit", handlers.AuditLogHandler)
+			})
+		})
+	})
+	
+	// Admin routes (require the ADMIN_TOKEN shared secret)
+	r.Group(func(r chi.Router) {
+		r.Use(utils.AdminTokenMiddleware)
+		
+		r.Post("/api/admin/snapshot", handlers.SnapshotHandler)
+		r.Post("/api/admin/restore", handlers.RestoreHandler)
+		r.Get("/api/admin/audit", handlers.AdminAuditLogHandler)
 	})
 	
 	// Serve the SPA for any routes not matched
@@ -159,9 +264,125 @@ func setupRoutes() http.Handler {
 }
 
 func main() {
-	// Initialize database
-	database.InitDB()
+	// Snapshot interval for the memory driver (SNAPSHOT_INTERVAL, e.g. "5m")
+	snapshotInterval, err := time.ParseDuration(getEnv("SNAPSHOT_INTERVAL", "5m"))
+	if err != nil {
+		log.Fatal("Invalid SNAPSHOT_INTERVAL:", err)
+	}
+	
+	// Initialize database (DB_DRIVER=memory|bolt, DB_PATH for file-backed drivers,
+	// SNAPSHOT_PATH to persist the memory driver between restarts)
+	store, err := database.InitDB(database.Config{
+		Driver:           getEnv("DB_DRIVER", database.DriverMemory),
+		Path:             getEnv("DB_PATH", "resume-analyzer.db"),
+		SnapshotPath:     os.Getenv("SNAPSHOT_PATH"),
+		SnapshotInterval: snapshotInterval,
+	})
+	if err != nil {
+		log.Fatal("Database error:", err)
+	}
 	defer database.CloseDB()
+	models.SetStore(store)
+	
+	// Password hashing cost (BCRYPT_COST, defaults to bcrypt's default)
+	if costValue := os.Getenv("BCRYPT_COST"); costValue != "" {
+		cost, err := strconv.Atoi(costValue)
+		if err == nil {
+			err = models.SetPasswordCost(cost)
+		}
+		if err != nil {
+			log.Fatal("Invalid BCRYPT_COST:", err)
+		}
+	}
+	
+	// Copy of the audit trail as JSON lines (AUDIT_LOG_FILE, optional)
+	if err := models.SetAuditFile(os.Getenv("AUDIT_LOG_FILE")); err != nil {
+		log.Fatal("Audit log error:", err)
+	}
+	
+	// Run a maintenance command instead of the server if one was given
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			database.CloseDB()
+			log.Fatal(err)
+		}
+		return
+	}
+	
+	// JWT signing keys (see utils.LoadKeyringFromEnv for the variables)
+	keyring, err := utils.LoadKeyringFromEnv()
+	if err != nil {
+		log.Fatal("JWT key error:", err)
+	}
+	utils.SetKeyring(keyring)
+	utils.SetRevocationCheck(models.IsAccessTokenRevoked)
+	utils.SetSessionCheck(models.IsSessionCurrent)
+	utils.SetAPIKeyAuthenticator(handlers.APIKeyPrincipal)
+	
+	// Sign-in methods (LOCAL_AUTH=false disables local accounts; see
+	// utils.LoadOIDCProviderFromEnv for single sign-on)
+	localLogin, err := strconv.ParseBool(getEnv("LOCAL_AUTH", "true"))
+	if err != nil {
+		log.Fatal("Invalid LOCAL_AUTH:", err)
+	}
+	oidcProvider, err := utils.LoadOIDCProviderFromEnv(context.Background())
+	if err != nil {
+		log.Fatal("OIDC error:", err)
+	}
+	if !localLogin && oidcProvider == nil {
+		log.Fatal("LOCAL_AUTH=false requires OIDC_ISSUER to be configured")
+	}
+	
+	// Failed login limits (LOGIN_MAX_FAILURES per username before a lockout
+	// of LOGIN_LOCKOUT; each IP gets ten times as many failures)
+	userPolicy, ipPolicy := utils.DefaultUserPolicy, utils.DefaultIPPolicy
+	if value := os.Getenv("LOGIN_MAX_FAILURES"); value != "" {
+		maxFailures, err := strconv.Atoi(value)
+		if err != nil || maxFailures < 1 {
+			log.Fatal("Invalid LOGIN_MAX_FAILURES:", value)
+		}
+		userPolicy.MaxFailures = maxFailures
+		ipPolicy.MaxFailures = maxFailures * 10
+	}
+	if value := os.Getenv("LOGIN_LOCKOUT"); value != "" {
+		lockout, err := time.ParseDuration(value)
+		if err != nil || lockout <= 0 {
+			log.Fatal("Invalid LOGIN_LOCKOUT:", value)
+		}
+		userPolicy.Lockout = lockout
+		ipPolicy.Lockout = lockout
+	}
+	throttle := utils.NewLoginThrottle(utils.NewMemoryAttemptStore(), userPolicy, ipPolicy)
+	throttle.OnLockout = handlers.RecordLockout
+	
+	handlers.ConfigureAuth(handlers.AuthOptions{LocalLogin: localLogin, OIDC: oidcProvider, Throttle: throttle})
+	
+	// Account email (see utils.LoadMailerFromEnv; APP_URL is the public URL
+	// used in links, REQUIRE_VERIFIED_EMAIL=true blocks unverified uploads)
+	mailer, err := utils.LoadMailerFromEnv()
+	if err != nil {
+		log.Fatal("Mail error:", err)
+	}
+	requireVerified, err := strconv.ParseBool(getEnv("REQUIRE_VERIFIED_EMAIL", "false"))
+	if err != nil {
+		log.Fatal("Invalid REQUIRE_VERIFIED_EMAIL:", err)
+	}
+	handlers.ConfigureMail(handlers.MailOptions{
+		Mailer:               mailer,
+		BaseURL:              getEnv("APP_URL", "http://localhost:8081"),
+		RequireVerifiedEmail: requireVerified,
+	})
+	
+	// Delete resumes past their organization's retention period
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			if _, err := models.PurgeExpiredResumes(); err != nil {
+				log.Println("Error purging expired resumes:", err)
+			}
+		}
+	}()
 	
 	// Create uploads directory if it doesn't exist
 	uploadsDir := "./uploads"
@@ -178,7 +399,19 @@ func main() {
 	fmt.Printf("Access the legacy app at http://localhost:%s/\n", port)
 	fmt.Printf("Access the new enhanced app at http://localhost:%s/app\n", port)
 	
-	if err := http.ListenAndServe(":"+port, router); err != nil {
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	
+	// Shut down cleanly on Ctrl-C / SIGTERM so the database is flushed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatal("Server error:", err)
 	}
 }
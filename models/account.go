@@ -0,0 +1,89 @@
+package models
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"webscrapper/database"
+)
+
+// ErrInvalidAccountLink is returned when a password reset or verification
+// link no longer matches the account, e.g. because it was already used
+var ErrInvalidAccountLink = errors.New("invalid or expired link")
+
+// GetUserByEmail retrieves a user by their email address
+func GetUserByEmail(email string) (*User, error) {
+	dbUser, err := store.GetUserByEmail(email)
+	if err != nil || dbUser == nil {
+		return nil, err
+	}
+	return toUser(dbUser), nil
+}
+
+// PasswordResetBinding returns the value a password reset link is tied to.
+// It changes with the password, so a link stops working once used. Users
+// without a local password get an empty binding and cannot reset.
+func PasswordResetBinding(userID string) (string, error) {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil || dbUser == nil || dbUser.Password == "" {
+		return "", err
+	}
+	return hashRefreshToken("password\x00" + dbUser.Password)[:32], nil
+}
+
+// EmailVerificationBinding returns the value a verification link is tied
+// to. It changes with the email address, so old links cannot verify a new
+// address.
+func EmailVerificationBinding(userID string) (string, error) {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil || dbUser == nil || dbUser.Email == "" {
+		return "", err
+	}
+	return hashRefreshToken("email\x00" + database.NormalizeEmail(dbUser.Email))[:32], nil
+}
+
+// ResetPassword sets a new password through a reset link. Following the
+// link proves control of the email, so the address is marked verified,
+// and every session from before the reset is ended.
+func ResetPassword(userID, binding, password string) error {
+	current, err := PasswordResetBinding(userID)
+	if err != nil {
+		return err
+	}
+	if current == "" || subtle.ConstantTimeCompare([]byte(current), []byte(binding)) != 1 {
+		return ErrInvalidAccountLink
+	}
+
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	dbUser.Password = hashedPassword
+	dbUser.PasswordChangedAt = time.Now()
+	dbUser.EmailVerified = true
+	return store.SaveUser(dbUser)
+}
+
+// VerifyEmail marks a user's email address verified through a
+// verification link
+func VerifyEmail(userID, binding string) error {
+	current, err := EmailVerificationBinding(userID)
+	if err != nil {
+		return err
+	}
+	if current == "" || subtle.ConstantTimeCompare([]byte(current), []byte(binding)) != 1 {
+		return ErrInvalidAccountLink
+	}
+
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	dbUser.EmailVerified = true
+	return store.SaveUser(dbUser)
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"webscrapper/database"
+)
+
+// apiKeyPrefix marks API keys so they are recognizable in logs and scanners
+const apiKeyPrefix = "rak_"
+
+// API key scopes
+const (
+	ScopeResumesRead  = "resumes:read"
+	ScopeResumesWrite = "resumes:write"
+	ScopeJobsRead     = "jobs:read"
+	ScopeJobsWrite    = "jobs:write"
+)
+
+// API key errors
+var (
+	ErrInvalidScope   = errors.New("invalid API key scope")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKey represents an API key as shown to its owner. The secret itself is
+// only returned once, by CreateAPIKey.
+type APIKey struct {
+	ID        string     `json:"id"`
+	Prefix    string     `json:"prefix"`
+	Name      string     `json:"name"`
+	UserID    string     `json:"user_id"`
+	OrgID     string     `json:"org_id,omitempty"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ValidScope reports whether scope is a known API key scope
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeResumesRead, ScopeResumesWrite, ScopeJobsRead, ScopeJobsWrite:
+		return true
+	}
+	return false
+}
+
+// toAPIKey converts a database API key
+func toAPIKey(dbKey *database.APIKey) *APIKey {
+	return &APIKey{
+		ID:        dbKey.ID,
+		Prefix:    dbKey.Prefix,
+		Name:      dbKey.Name,
+		UserID:    dbKey.UserID,
+		OrgID:     dbKey.OrgID,
+		Scopes:    dbKey.Scopes,
+		CreatedAt: dbKey.CreatedAt,
+		RevokedAt: dbKey.RevokedAt,
+	}
+}
+
+// CreateAPIKey creates an API key acting as a user within their current
+// organization and returns the raw key, which is never stored
+func CreateAPIKey(userID, orgID, name string, scopes []string) (string, *APIKey, error) {
+	if len(scopes) == 0 {
+		return "", nil, ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !ValidScope(scope) {
+			return "", nil, ErrInvalidScope
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	dbKey := &database.APIKey{
+		ID:        uuid.New().String(),
+		Hash:      hashRefreshToken(secret),
+		Prefix:    secret[:len(apiKeyPrefix)+6],
+		Name:      strings.TrimSpace(name),
+		UserID:    userID,
+		OrgID:     orgID,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	if err := store.SaveAPIKey(dbKey); err != nil {
+		return "", nil, err
+	}
+	return secret, toAPIKey(dbKey), nil
+}
+
+// GetAPIKeyByID retrieves an API key, returning nil if it does not exist
+func GetAPIKeyByID(id string) (*APIKey, error) {
+	dbKey, err := store.GetAPIKeyByID(id)
+	if err != nil || dbKey == nil {
+		return nil, err
+	}
+	return toAPIKey(dbKey), nil
+}
+
+// GetAPIKeys lists the API keys of an organization, or of a single user
+// within it when userID is not empty
+func GetAPIKeys(orgID, userID string) ([]*APIKey, error) {
+	dbKeys, err := store.GetAPIKeys(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*APIKey, 0, len(dbKeys))
+	for _, dbKey := range dbKeys {
+		keys = append(keys, toAPIKey(dbKey))
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey permanently disables an API key. Revoking a revoked key is
+// not an error.
+func RevokeAPIKey(id string) error {
+	dbKey, err := store.GetAPIKeyByID(id)
+	if err != nil {
+		return err
+	}
+	if dbKey == nil {
+		return ErrAPIKeyNotFound
+	}
+	if dbKey.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	dbKey.RevokedAt = &now
+	return store.SaveAPIKey(dbKey)
+}
+
+// AuthenticateAPIKey resolves a raw API key to its key and owner.
+// It returns nil for unknown or revoked keys, and for keys whose owner has
+// since left the organization the key was created in.
+func AuthenticateAPIKey(secret string) (*APIKey, *User, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil, nil, nil
+	}
+	dbKey, err := store.GetAPIKeyByHash(hashRefreshToken(secret))
+	if err != nil || dbKey == nil || dbKey.RevokedAt != nil {
+		return nil, nil, err
+	}
+	dbUser, err := store.GetUserByID(dbKey.UserID)
+	if err != nil || dbUser == nil || dbUser.OrgID != dbKey.OrgID {
+		return nil, nil, err
+	}
+	return toAPIKey(dbKey), toUser(dbUser), nil
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"webscrapper/database"
+)
+
+// Audit actions
+const (
+	AuditLogin          = "auth.login"
+	AuditLoginFailed    = "auth.login_failed"
+	AuditLockout        = "auth.lockout"
+	AuditLogout         = "auth.logout"
+	AuditRegister       = "auth.register"
+	AuditPasswordReset  = "auth.password_reset"
+	AuditEmailVerified  = "account.email_verified"
+	AuditProfileUpdate  = "account.update"
+	AuditPasswordChange = "account.password_change"
+	AuditAccountDelete  = "account.delete"
+	AuditMFAEnable      = "mfa.enable"
+	AuditMFADisable     = "mfa.disable"
+	AuditAPIKeyCreate   = "apikey.create"
+	AuditAPIKeyRevoke   = "apikey.revoke"
+	AuditResumeUpload   = "resume.upload"
+	AuditResumeView     = "resume.view"
+	AuditResumeListOrg  = "resume.list_org"
+	AuditResumeSearch   = "resume.search_org"
+	AuditResumePurge    = "resume.purge"
+	AuditResumeUpdate   = "resume.update"
+	AuditResumeDelete   = "resume.delete"
+	AuditJobCreate      = "job.create"
+	AuditJobUpdate      = "job.update"
+	AuditJobDelete      = "job.delete"
+	AuditJobMatches     = "job.matches_org"
+	AuditOrgCreate      = "org.create"
+	AuditOrgSettings    = "org.settings_update"
+	AuditInvitation     = "org.invitation_create"
+	AuditJoinOrg        = "org.join"
+	AuditMemberRole     = "org.member_role"
+	AuditMemberRemove   = "org.member_remove"
+	AuditExport         = "admin.export"
+	AuditRestore        = "admin.restore"
+)
+
+// AuditEvent is an entry of the audit trail
+type AuditEvent struct {
+	Seq        uint64            `json:"seq"`
+	Time       time.Time         `json:"time"`
+	Action     string            `json:"action"`
+	ActorID    string            `json:"actor_id,omitempty"`
+	OrgID      string            `json:"org_id,omitempty"`
+	TargetType string            `json:"target_type,omitempty"`
+	TargetID   string            `json:"target_id,omitempty"`
+	IP         string            `json:"ip,omitempty"`
+	UserAgent  string            `json:"user_agent,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+}
+
+// AuditFilter selects audit events, see database.AuditFilter
+type AuditFilter = database.AuditFilter
+
+// auditFile receives a copy of every audit event as a line of JSON
+var (
+	auditFile      *os.File
+	auditFileMutex sync.Mutex
+)
+
+// SetAuditFile appends every audit event to the file at path as well as
+// storing it. An empty path stops writing to a file.
+func SetAuditFile(path string) error {
+	auditFileMutex.Lock()
+	defer auditFileMutex.Unlock()
+	if auditFile != nil {
+		auditFile.Close()
+		auditFile = nil
+	}
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	auditFile = f
+	return nil
+}
+
+// RecordAudit appends an event to the audit trail. Failures are logged
+// rather than returned so auditing never breaks the request it describes.
+func RecordAudit(event AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	dbEvent := database.AuditEvent(event)
+	if err := store.AppendAuditEvent(&dbEvent); err != nil {
+		log.Printf("Error recording audit event %s: %v", event.Action, err)
+	}
+	event.Seq = dbEvent.Seq
+
+	auditFileMutex.Lock()
+	defer auditFileMutex.Unlock()
+	if auditFile == nil {
+		return
+	}
+	line, err := json.Marshal(event)
+	if err == nil {
+		_, err = auditFile.Write(append(line, '\n'))
+	}
+	if err != nil {
+		log.Printf("Error writing audit event %s to file: %v", event.Action, err)
+	}
+}
+
+// GetAuditEvents returns matching audit events, newest first
+func GetAuditEvents(filter AuditFilter) ([]*AuditEvent, error) {
+	dbEvents, err := store.GetAuditEvents(filter)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*AuditEvent, 0, len(dbEvents))
+	for _, dbEvent := range dbEvents {
+		event := AuditEvent(*dbEvent)
+		events = append(events, &event)
+	}
+	return events, nil
+}
@@ -0,0 +1,279 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"webscrapper/database"
+)
+
+// Limits on job fields
+const (
+	MaxJobTitleLength       = 200
+	MaxJobDescriptionLength = 20000
+	MaxJobSkills            = 50
+	MaxJobSkillLength       = 50
+	MaxJobMinYears          = 50
+	MaxJobRubricWeight      = 100
+)
+
+// Job errors
+var (
+	ErrJobTitleRequired     = errors.New("title is required")
+	ErrJobTitleLength       = errors.New("title is too long")
+	ErrJobDescriptionLength = errors.New("description is too long")
+	ErrJobSkills            = errors.New("a job can list at most 50 skills of 1 to 50 bytes each")
+	ErrJobMinYears          = errors.New("min_years must be between 0 and 50")
+	ErrJobRubric            = errors.New("rubric weights must be between 0 and 100 and not all 0")
+)
+
+// Job is a job description resumes are matched against
+type Job struct {
+	ID               string     `json:"id"`
+	OrgID            string     `json:"org_id,omitempty"`
+	OwnerID          string     `json:"owner_id"`
+	Title            string     `json:"title"`
+	Description      string     `json:"description"`
+	RequiredSkills   []string   `json:"required_skills"`
+	NiceToHaveSkills []string   `json:"nice_to_have_skills"`
+	MinYears         int        `json:"min_years"`
+	MinEducation     string     `json:"min_education,omitempty"`
+	Rubric           *JobRubric `json:"rubric,omitempty"` // Default weights when nil
+	Keywords         []string   `json:"keywords"`
+	DetectedSkills   []string   `json:"detected_skills"`
+	Education        []string   `json:"education"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// JobRubric weighs the components of a job's match scores
+type JobRubric struct {
+	RequiredSkills   float64 `json:"required_skills"`
+	NiceToHaveSkills float64 `json:"nice_to_have_skills"`
+	Experience       float64 `json:"experience"`
+	Education        float64 `json:"education"`
+	Keywords         float64 `json:"keywords"`
+}
+
+// JobAnalysis is what the analyzer extracted from a job description
+type JobAnalysis struct {
+	Keywords  []string
+	Skills    []string
+	Education []string
+}
+
+// JobUpdate lists the job fields to change; nil fields are kept. Analysis
+// must be set when the description changes, and MinEducation checked by the
+// caller against the analyzer's education levels.
+type JobUpdate struct {
+	Title            *string
+	Description      *string
+	RequiredSkills   *[]string
+	NiceToHaveSkills *[]string
+	MinYears         *int
+	MinEducation     *string
+	Rubric           *JobRubric
+	Analysis         *JobAnalysis
+}
+
+// toJob converts a database job
+func toJob(dbJob *database.Job) *Job {
+	return &Job{
+		ID:               dbJob.ID,
+		OrgID:            dbJob.OrgID,
+		OwnerID:          dbJob.OwnerID,
+		Title:            dbJob.Title,
+		Description:      dbJob.Description,
+		RequiredSkills:   dbJob.RequiredSkills,
+		NiceToHaveSkills: dbJob.NiceToHaveSkills,
+		MinYears:         dbJob.MinYears,
+		MinEducation:     dbJob.MinEducation,
+		Rubric:           toJobRubric(dbJob.Rubric),
+		Keywords:         dbJob.Keywords,
+		DetectedSkills:   dbJob.DetectedSkills,
+		Education:        dbJob.Education,
+		CreatedAt:        dbJob.CreatedAt,
+		UpdatedAt:        dbJob.UpdatedAt,
+	}
+}
+
+// toJobRubric converts a database job rubric
+func toJobRubric(dbRubric *database.JobRubric) *JobRubric {
+	if dbRubric == nil {
+		return nil
+	}
+	return &JobRubric{
+		RequiredSkills:   dbRubric.RequiredSkills,
+		NiceToHaveSkills: dbRubric.NiceToHaveSkills,
+		Experience:       dbRubric.Experience,
+		Education:        dbRubric.Education,
+		Keywords:         dbRubric.Keywords,
+	}
+}
+
+// CreateJob saves a new job owned by ownerID in an organization
+func CreateJob(ownerID, orgID string, update JobUpdate) (*Job, error) {
+	now := time.Now()
+	dbJob := &database.Job{
+		ID:        uuid.New().String(),
+		OrgID:     orgID,
+		OwnerID:   ownerID,
+		CreatedAt: now,
+	}
+	if err := applyJobUpdate(dbJob, update); err != nil {
+		return nil, err
+	}
+	if dbJob.Title == "" {
+		return nil, ErrJobTitleRequired
+	}
+	if err := store.SaveJob(dbJob); err != nil {
+		return nil, err
+	}
+	return toJob(dbJob), nil
+}
+
+// GetJobByID retrieves a job by its ID within an organization
+func GetJobByID(orgID, id string) (*Job, error) {
+	dbJob, err := store.GetJobByID(orgID, id)
+	if err != nil || dbJob == nil {
+		return nil, err
+	}
+	return toJob(dbJob), nil
+}
+
+// GetJobs retrieves the jobs of an organization, or only those owned by
+// ownerID when it is set, newest first
+func GetJobs(orgID, ownerID string) ([]*Job, error) {
+	dbJobs, err := store.GetJobs(orgID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	jobs := []*Job{}
+	for _, dbJob := range dbJobs {
+		jobs = append(jobs, toJob(dbJob))
+	}
+
+	// Newest first; the stores return jobs in no particular order
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs, nil
+}
+
+// UpdateJob changes a job. It returns nil when the job does not exist in
+// the organization.
+func UpdateJob(orgID, id string, update JobUpdate) (*Job, error) {
+	dbJob, err := store.GetJobByID(orgID, id)
+	if err != nil || dbJob == nil {
+		return nil, err
+	}
+	if err := applyJobUpdate(dbJob, update); err != nil {
+		return nil, err
+	}
+	if dbJob.Title == "" {
+		return nil, ErrJobTitleRequired
+	}
+	if err := store.SaveJob(dbJob); err != nil {
+		return nil, err
+	}
+	return toJob(dbJob), nil
+}
+
+// DeleteJob deletes a job
+func DeleteJob(orgID, id string) error {
+	return store.DeleteJob(orgID, id)
+}
+
+// applyJobUpdate checks and copies the set fields of update onto dbJob
+func applyJobUpdate(dbJob *database.Job, update JobUpdate) error {
+	if update.Title != nil {
+		title := strings.TrimSpace(*update.Title)
+		if len(title) > MaxJobTitleLength {
+			return ErrJobTitleLength
+		}
+		dbJob.Title = title
+	}
+	if update.Description != nil {
+		if len(*update.Description) > MaxJobDescriptionLength {
+			return ErrJobDescriptionLength
+		}
+		dbJob.Description = *update.Description
+	}
+	if update.RequiredSkills != nil {
+		skills, err := normalizeJobSkills(*update.RequiredSkills)
+		if err != nil {
+			return err
+		}
+		dbJob.RequiredSkills = skills
+	}
+	if update.NiceToHaveSkills != nil {
+		skills, err := normalizeJobSkills(*update.NiceToHaveSkills)
+		if err != nil {
+			return err
+		}
+		dbJob.NiceToHaveSkills = skills
+	}
+	if update.MinYears != nil {
+		if *update.MinYears < 0 || *update.MinYears > MaxJobMinYears {
+			return ErrJobMinYears
+		}
+		dbJob.MinYears = *update.MinYears
+	}
+	if update.MinEducation != nil {
+		dbJob.MinEducation = *update.MinEducation
+	}
+	if update.Rubric != nil {
+		rubric := *update.Rubric
+		weights := []float64{rubric.RequiredSkills, rubric.NiceToHaveSkills, rubric.Experience, rubric.Education, rubric.Keywords}
+		total := 0.0
+		for _, weight := range weights {
+			if weight < 0 || weight > MaxJobRubricWeight {
+				return ErrJobRubric
+			}
+			total += weight
+		}
+		if total == 0 {
+			return ErrJobRubric
+		}
+		dbJob.Rubric = &database.JobRubric{
+			RequiredSkills:   rubric.RequiredSkills,
+			NiceToHaveSkills: rubric.NiceToHaveSkills,
+			Experience:       rubric.Experience,
+			Education:        rubric.Education,
+			Keywords:         rubric.Keywords,
+		}
+	}
+	if update.Analysis != nil {
+		dbJob.Keywords = update.Analysis.Keywords
+		dbJob.DetectedSkills = update.Analysis.Skills
+		dbJob.Education = update.Analysis.Education
+	}
+	dbJob.UpdatedAt = time.Now()
+	return nil
+}
+
+// normalizeJobSkills trims and lowercases skills and drops duplicates
+func normalizeJobSkills(skills []string) ([]string, error) {
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, skill := range skills {
+		skill = strings.ToLower(strings.TrimSpace(skill))
+		if skill == "" || len(skill) > MaxJobSkillLength {
+			return nil, ErrJobSkills
+		}
+		if !seen[skill] {
+			seen[skill] = true
+			normalized = append(normalized, skill)
+		}
+	}
+	if len(normalized) > MaxJobSkills {
+		return nil, ErrJobSkills
+	}
+	return normalized, nil
+}
@@ -0,0 +1,252 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"webscrapper/database"
+)
+
+// TOTP parameters (RFC 6238 defaults, which authenticator apps expect)
+const (
+	totpPeriod = 30 // seconds per time step
+	totpDigits = 6
+	totpSkew   = 1 // steps of clock drift accepted either side
+)
+
+// totpIssuer names the service in authenticator apps
+const totpIssuer = "Resume Analyzer"
+
+// recoveryCodeCount is how many recovery codes are issued on enrollment
+const recoveryCodeCount = 10
+
+// MFA errors
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication enrollment was not started")
+	ErrMFAInvalidCode    = errors.New("invalid two-factor authentication code")
+	ErrMFARequired       = errors.New("the organization requires two-factor authentication")
+)
+
+// totpEncoding is base32 without padding, as used in otpauth URIs
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAEnrollment is a started TOTP enrollment
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"` // Render as a QR code for authenticator apps
+}
+
+// MFARequired reports whether a user must pass a second factor to log in,
+// either because they enrolled or because their organization requires it
+func MFARequired(userID string) (bool, error) {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil || dbUser == nil {
+		return false, err
+	}
+	return dbUser.TOTPEnabled || orgSettings(dbUser.OrgID).RequireMFA, nil
+}
+
+// MFASetupRequired reports whether a user must enroll in TOTP before they
+// may sign in, because their organization requires MFA
+func MFASetupRequired(userID string) (bool, error) {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil || dbUser == nil {
+		return false, err
+	}
+	return !dbUser.TOTPEnabled && orgSettings(dbUser.OrgID).RequireMFA, nil
+}
+
+// BeginTOTPEnrollment generates a new TOTP secret for a user. It takes
+// effect once confirmed with ConfirmTOTPEnrollment.
+func BeginTOTPEnrollment(userID string) (*MFAEnrollment, error) {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if dbUser == nil {
+		return nil, errors.New("user not found")
+	}
+	if dbUser.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	dbUser.TOTPSecret = totpEncoding.EncodeToString(secret)
+	if err := store.SaveUser(dbUser); err != nil {
+		return nil, err
+	}
+
+	label := url.PathEscape(totpIssuer + ":" + dbUser.Username)
+	params := url.Values{
+		"secret":    {dbUser.TOTPSecret},
+		"issuer":    {totpIssuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(totpPeriod)},
+	}
+	return &MFAEnrollment{
+		Secret:     dbUser.TOTPSecret,
+		OTPAuthURI: "otpauth://totp/" + label + "?" + params.Encode(),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment enables TOTP once the user proves their app works
+// by entering a current code. It returns the raw recovery codes, which are
+// never shown again.
+func ConfirmTOTPEnrollment(userID, code string) ([]string, error) {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if dbUser == nil {
+		return nil, errors.New("user not found")
+	}
+	if dbUser.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if dbUser.TOTPSecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+	if !checkTOTP(dbUser, code) {
+		return nil, ErrMFAInvalidCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	dbUser.TOTPEnabled = true
+	dbUser.RecoveryCodes = hashes
+	if err := store.SaveUser(dbUser); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns TOTP off after checking a current code or recovery code.
+// Members of organizations that require MFA cannot turn it off.
+func DisableTOTP(userID, code string) error {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if dbUser == nil || !dbUser.TOTPEnabled {
+		return ErrMFANotEnrolled
+	}
+	if orgSettings(dbUser.OrgID).RequireMFA {
+		return ErrMFARequired
+	}
+	if !checkSecondFactor(dbUser, code) {
+		return ErrMFAInvalidCode
+	}
+
+	dbUser.TOTPSecret = ""
+	dbUser.TOTPEnabled = false
+	dbUser.TOTPLastCounter = 0
+	dbUser.RecoveryCodes = nil
+	return store.SaveUser(dbUser)
+}
+
+// VerifySecondFactor checks a TOTP code or a recovery code for a user with
+// TOTP enabled. Recovery codes are consumed, and a TOTP code is not
+// accepted twice.
+func VerifySecondFactor(userID, code string) (bool, error) {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil || dbUser == nil || !dbUser.TOTPEnabled {
+		return false, err
+	}
+	if !checkSecondFactor(dbUser, code) {
+		return false, nil
+	}
+	return true, store.SaveUser(dbUser)
+}
+
+// checkSecondFactor checks a TOTP code or recovery code against dbUser,
+// updating the replay counter or recovery codes in place. The caller saves.
+func checkSecondFactor(dbUser *database.User, code string) bool {
+	if checkTOTP(dbUser, code) {
+		return true
+	}
+
+	// Recovery codes are case-insensitive and may be typed without the dash
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	hash := hashRefreshToken(normalized)
+	for i, stored := range dbUser.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			dbUser.RecoveryCodes = append(dbUser.RecoveryCodes[:i:i], dbUser.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// checkTOTP checks a TOTP code against dbUser's secret, allowing for clock
+// skew. Time steps at or before the last accepted one are rejected.
+func checkTOTP(dbUser *database.User, code string) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return false
+	}
+	secret, err := totpEncoding.DecodeString(dbUser.TOTPSecret)
+	if err != nil || len(secret) == 0 {
+		return false
+	}
+
+	now := uint64(time.Now().Unix() / totpPeriod)
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if step <= dbUser.TOTPLastCounter {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			dbUser.TOTPLastCounter = step
+			return true
+		}
+	}
+	return false
+}
+
+// totpCode computes the RFC 4226 HOTP value for a counter
+func totpCode(secret []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulus := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%modulus)
+}
+
+// generateRecoveryCodes returns fresh recovery codes and their hashes
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(raw)
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashRefreshToken(code))
+	}
+	return codes, hashes, nil
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"webscrapper/database"
+)
+
+// OIDC account errors
+var (
+	ErrOIDCEmailUnverified = errors.New("identity provider did not verify the email of an existing account")
+	ErrOIDCAlreadyLinked   = errors.New("account is already linked to another external identity")
+	ErrOIDCLocalUnverified = errors.New("existing account with this email has not verified it")
+)
+
+// usernameUnsafe matches characters not allowed in generated usernames
+var usernameUnsafe = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// LoginWithOIDC returns the user for an external identity.
+// A user already linked to the identity is returned as is. Otherwise an
+// account with the same email, verified both by the IdP and locally, is
+// linked, and failing that a new candidate account without a local
+// password is provisioned.
+func LoginWithOIDC(issuer, subject, email string, emailVerified bool, username string) (*User, error) {
+	// Returning user
+	dbUser, err := store.GetUserByOIDCSubject(issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+	if dbUser != nil {
+		return toUser(dbUser), nil
+	}
+
+	// Link an existing account, but only on a verified email so an IdP
+	// account cannot claim someone else's address
+	email = strings.TrimSpace(email)
+	if email != "" {
+		dbUser, err = store.GetUserByEmail(email)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if dbUser != nil {
+		if !emailVerified {
+			return nil, ErrOIDCEmailUnverified
+		}
+		// Anyone can put an address on a local account; linking one that
+		// never proved it would hand its password to whoever set it
+		if !dbUser.EmailVerified {
+			return nil, ErrOIDCLocalUnverified
+		}
+		if dbUser.OIDCSubject != "" {
+			return nil, ErrOIDCAlreadyLinked
+		}
+		dbUser.OIDCIssuer = issuer
+		dbUser.OIDCSubject = subject
+		if err := store.SaveUser(dbUser); err != nil {
+			return nil, err
+		}
+		return toUser(dbUser), nil
+	}
+
+	// Provision a new account. Unverified emails are not recorded.
+	if !emailVerified {
+		email = ""
+	}
+	base := oidcUsername(username, email)
+	dbUser = &database.User{
+		ID:            uuid.New().String(),
+		Username:      base,
+		Email:         email,
+		EmailVerified: email != "",
+		Role:          RoleCandidate,
+		CreatedAt:     time.Now(),
+		OIDCIssuer:    issuer,
+		OIDCSubject:   subject,
+	}
+	for attempt := 0; ; attempt++ {
+		err = store.SaveUser(dbUser)
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) || conflict.Field != "username" || attempt == 4 {
+			break
+		}
+		// Taken; add a random suffix and try again
+		suffix := make([]byte, 2)
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, err
+		}
+		dbUser.Username = base + "-" + hex.EncodeToString(suffix)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toUser(dbUser), nil
+}
+
+// oidcUsername derives a username from the IdP's preferred username or
+// the email's local part
+func oidcUsername(preferred, email string) string {
+	name := preferred
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	name = strings.Trim(usernameUnsafe.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if name == "" {
+		name = "user"
+	}
+	return name
+}
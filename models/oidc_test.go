@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"webscrapper/database"
+)
+
+func TestLoginWithOIDCLinking(t *testing.T) {
+	tests := []struct {
+		name          string
+		localVerified bool
+		idpVerified   bool
+		wantErr       error
+	}{
+		{"both verified", true, true, nil},
+		{"idp unverified", true, false, ErrOIDCEmailUnverified},
+		// Someone else may have put the address on the local account
+		{"local unverified", false, true, ErrOIDCLocalUnverified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := database.NewMemoryStore()
+			SetStore(memory)
+			local := &database.User{
+				ID:            "local",
+				Username:      "alice",
+				Email:         "alice@example.com",
+				EmailVerified: tt.localVerified,
+				Password:      "hash",
+				Role:          RoleCandidate,
+				CreatedAt:     time.Now(),
+			}
+			if err := memory.SaveUser(local); err != nil {
+				t.Fatal(err)
+			}
+
+			user, err := LoginWithOIDC("https://idp.example.com", "sub-1", "alice@example.com", tt.idpVerified, "alice")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			stored, err := memory.GetUserByID("local")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != nil {
+				if stored.OIDCSubject != "" {
+					t.Error("refused login still linked the account")
+				}
+				return
+			}
+			if user.ID != "local" || stored.OIDCSubject != "sub-1" {
+				t.Errorf("user %s linked to %q, want local linked to sub-1", user.ID, stored.OIDCSubject)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"webscrapper/database"
+)
+
+// InvitationTTL is how long an invitation token stays valid
+var InvitationTTL = 7 * 24 * time.Hour
+
+// Organization errors
+var (
+	ErrAlreadyInOrg      = errors.New("user already belongs to an organization")
+	ErrOrgNotFound       = errors.New("organization not found")
+	ErrInvalidInvitation = errors.New("invalid or expired invitation")
+	ErrInvitationEmail   = errors.New("invitation was sent to a different email address")
+)
+
+// OrgSettings are per-organization options
+type OrgSettings struct {
+	SkillDictionary []string `json:"skill_dictionary"`
+	RetentionDays   int      `json:"retention_days"`
+	RequireMFA      bool     `json:"require_mfa"`
+}
+
+// Organization represents a tenant that owns users and resumes
+type Organization struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Settings  OrgSettings `json:"settings"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Invitation represents a pending invitation to join an organization
+type Invitation struct {
+	OrgID     string    `json:"org_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// toOrganization converts a database organization
+func toOrganization(dbOrg *database.Organization) *Organization {
+	return &Organization{
+		ID:   dbOrg.ID,
+		Name: dbOrg.Name,
+		Settings: OrgSettings{
+			SkillDictionary: dbOrg.Settings.SkillDictionary,
+			RetentionDays:   dbOrg.Settings.RetentionDays,
+			RequireMFA:      dbOrg.Settings.RequireMFA,
+		},
+		CreatedAt: dbOrg.CreatedAt,
+	}
+}
+
+// CreateOrganization creates an organization with the creator as its admin.
+// The creator's resumes move into the new organization.
+func CreateOrganization(name, creatorID string) (*Organization, error) {
+	creator, err := store.GetUserByID(creatorID)
+	if err != nil {
+		return nil, err
+	}
+	if creator == nil {
+		return nil, errors.New("user not found")
+	}
+	if creator.OrgID != "" {
+		return nil, ErrAlreadyInOrg
+	}
+
+	org := &database.Organization{
+		ID:        uuid.New().String(),
+		Name:      strings.TrimSpace(name),
+		CreatedAt: time.Now(),
+	}
+	if err := store.SaveOrganization(org); err != nil {
+		return nil, err
+	}
+	if err := moveUserToOrg(creator, org.ID, RoleAdmin); err != nil {
+		return nil, err
+	}
+	return toOrganization(org), nil
+}
+
+// GetOrganizationByID retrieves an organization, returning nil if it does not exist
+func GetOrganizationByID(id string) (*Organization, error) {
+	dbOrg, err := store.GetOrganizationByID(id)
+	if err != nil || dbOrg == nil {
+		return nil, err
+	}
+	return toOrganization(dbOrg), nil
+}
+
+// UpdateOrgSettings replaces an organization's settings
+func UpdateOrgSettings(orgID string, settings OrgSettings) (*Organization, error) {
+	dbOrg, err := store.GetOrganizationByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if dbOrg == nil {
+		return nil, ErrOrgNotFound
+	}
+
+	// Skills are matched against lowercased resume text
+	var skills []string
+	for _, skill := range settings.SkillDictionary {
+		if skill = strings.ToLower(strings.TrimSpace(skill)); skill != "" {
+			skills = append(skills, skill)
+		}
+	}
+	dbOrg.Settings = database.OrgSettings{
+		SkillDictionary: skills,
+		RetentionDays:   settings.RetentionDays,
+		RequireMFA:      settings.RequireMFA,
+	}
+	if err := store.SaveOrganization(dbOrg); err != nil {
+		return nil, err
+	}
+	return toOrganization(dbOrg), nil
+}
+
+// CreateInvitation invites an email address to join an organization with
+// a role and returns the raw invitation token, which is never stored
+func CreateInvitation(orgID, email, role, invitedBy string) (string, *Invitation, error) {
+	if !ValidRole(role) {
+		return "", nil, ErrInvalidRole
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	invitation := &database.Invitation{
+		Hash:      hashRefreshToken(token),
+		OrgID:     orgID,
+		Email:     strings.TrimSpace(email),
+		Role:      role,
+		InvitedBy: invitedBy,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(InvitationTTL),
+	}
+	if err := store.SaveInvitation(invitation); err != nil {
+		return "", nil, err
+	}
+	return token, &Invitation{
+		OrgID:     invitation.OrgID,
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		ExpiresAt: invitation.ExpiresAt,
+	}, nil
+}
+
+// AcceptInvitation adds a user to the organization an invitation was
+// issued for. The user's email must match the invited address.
+func AcceptInvitation(token, userID string) (*User, error) {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if dbUser == nil {
+		return nil, errors.New("user not found")
+	}
+
+	// Check the invitation before consuming it so a wrong account does not
+	// burn the invitee's token
+	hash := hashRefreshToken(token)
+	invitation, err := store.GetInvitation(hash)
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil || invitation.Accepted || time.Now().After(invitation.ExpiresAt) {
+		return nil, ErrInvalidInvitation
+	}
+	if database.NormalizeEmail(invitation.Email) != database.NormalizeEmail(dbUser.Email) {
+		return nil, ErrInvitationEmail
+	}
+	if dbUser.OrgID != "" && dbUser.OrgID != invitation.OrgID {
+		return nil, ErrAlreadyInOrg
+	}
+
+	// Consume it; a concurrent acceptance sees Accepted already set
+	invitation, err = store.UseInvitation(hash)
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil || invitation.Accepted {
+		return nil, ErrInvalidInvitation
+	}
+
+	if err := moveUserToOrg(dbUser, invitation.OrgID, invitation.Role); err != nil {
+		return nil, err
+	}
+	return toUser(dbUser), nil
+}
+
+// RemoveMember takes a user out of an organization. The user becomes a
+// candidate without an organization and takes their resumes along, and
+// their sessions end.
+func RemoveMember(orgID, userID string) error {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if dbUser == nil || dbUser.OrgID != orgID {
+		return nil
+	}
+	dbUser.SessionsRevokedAt = time.Now()
+	return moveUserToOrg(dbUser, "", RoleCandidate)
+}
+
+// moveUserToOrg changes a user's organization and role. Resumes always
+// live in their owner's organization, so they move along.
+func moveUserToOrg(dbUser *database.User, orgID, role string) error {
+	previousOrgID := dbUser.OrgID
+	dbUser.OrgID = orgID
+	dbUser.Role = role
+	if err := store.SaveUser(dbUser); err != nil {
+		return err
+	}
+	if previousOrgID == orgID {
+		return nil
+	}
+
+	resumes, err := store.GetResumesByUserID(previousOrgID, dbUser.ID)
+	if err != nil {
+		return err
+	}
+	for _, resume := range resumes {
+		moved := *resume
+		moved.OrgID = orgID
+		if err := store.SaveResume(&moved); err != nil {
+			return err
+		}
+		searchIndex.add(&moved)
+	}
+
+	// Personal jobs move along too; an organization's jobs stay with it
+	if previousOrgID != "" {
+		return nil
+	}
+	jobs, err := store.GetJobs("", dbUser.ID)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		moved := *job
+		moved.OrgID = orgID
+		if err := store.SaveJob(&moved); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orgSettings returns the settings of an organization, or the defaults for
+// users without one
+func orgSettings(orgID string) OrgSettings {
+	if orgID == "" {
+		return OrgSettings{}
+	}
+	dbOrg, err := store.GetOrganizationByID(orgID)
+	if err != nil || dbOrg == nil {
+		return OrgSettings{}
+	}
+	return toOrganization(dbOrg).Settings
+}
+
+// SkillDictionary returns the extra skills an organization recognizes
+func SkillDictionary(orgID string) []string {
+	return orgSettings(orgID).SkillDictionary
+}
+
+// retentionCutoff returns the upload time before which an organization's
+// resumes have expired, or the zero time if they never expire
+func retentionCutoff(orgID string) time.Time {
+	days := orgSettings(orgID).RetentionDays
+	if days <= 0 {
+		return time.Time{}
+	}
+	return time.Now().AddDate(0, 0, -days)
+}
+
+// PurgeExpiredResumes deletes resumes older than their organization's
+// retention period and returns how many were removed
+func PurgeExpiredResumes() (int, error) {
+	orgs, err := store.GetOrganizations()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, org := range orgs {
+		cutoff := retentionCutoff(org.ID)
+		if cutoff.IsZero() {
+			continue
+		}
+		resumes, err := store.GetResumesByOrgID(org.ID)
+		if err != nil {
+			return purged, err
+		}
+		for _, resume := range resumes {
+			if resume.UploadedAt.Before(cutoff) {
+				if err := store.DeleteResume(org.ID, resume.ID); err != nil {
+					return purged, err
+				}
+				searchIndex.remove(resume.ID)
+				if _, err := removeResumeFile(resume); err != nil {
+					log.Printf("Error removing file of purged resume %s: %v", resume.ID, err)
+				}
+				RecordAudit(AuditEvent{
+					Action:     AuditResumePurge,
+					OrgID:      org.ID,
+					TargetType: "resume",
+					TargetID:   resume.ID,
+					Details:    map[string]string{"owner_id": resume.UserID},
+				})
+				purged++
+			}
+		}
+	}
+	if purged > 0 {
+		log.Printf("Purged %d resumes past their retention period", purged)
+	}
+	return purged, nil
+}
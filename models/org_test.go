@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"webscrapper/database"
+)
+
+func TestMembershipChangeEndsSessions(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(userID string) error
+	}{
+		{"demoted", func(userID string) error {
+			_, err := UpdateUserRole(userID, RoleCandidate)
+			return err
+		}},
+		{"removed", func(userID string) error {
+			return RemoveMember("org-1", userID)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := database.NewMemoryStore()
+			SetStore(memory)
+			member := &database.User{ID: "member", Username: "bob", Role: RoleRecruiter, OrgID: "org-1", CreatedAt: time.Now()}
+			if err := memory.SaveUser(member); err != nil {
+				t.Fatal(err)
+			}
+			refresh, _, err := IssueRefreshToken(member.ID, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Token times have whole seconds
+			issued := time.Now().Truncate(time.Second)
+			if !IsSessionCurrent(member.ID, issued) {
+				t.Fatal("session is not current before the change")
+			}
+			if err := tt.change(member.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			// Tokens from before the change are rejected
+			if IsSessionCurrent(member.ID, issued) {
+				t.Error("access token from before the change is still current")
+			}
+			if _, _, _, err := RotateRefreshToken(refresh); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Errorf("refresh err = %v, want ErrInvalidRefreshToken", err)
+			}
+
+			// A new login works once the clock has moved on
+			if !IsSessionCurrent(member.ID, issued.Add(2*time.Second)) {
+				t.Error("access token from after the change is rejected")
+			}
+		})
+	}
+}
+
+func TestUnchangedRoleKeepsSessions(t *testing.T) {
+	memory := database.NewMemoryStore()
+	SetStore(memory)
+	member := &database.User{ID: "member", Username: "bob", Role: RoleRecruiter, OrgID: "org-1", CreatedAt: time.Now()}
+	if err := memory.SaveUser(member); err != nil {
+		t.Fatal(err)
+	}
+	issued := time.Now().Truncate(time.Second)
+	if _, err := UpdateUserRole(member.ID, RoleRecruiter); err != nil {
+		t.Fatal(err)
+	}
+	if !IsSessionCurrent(member.ID, issued) {
+		t.Error("setting the same role ended the session")
+	}
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// MaxDisplayNameLength is the longest display name accepted, in bytes
+const MaxDisplayNameLength = 100
+
+// Profile errors
+var (
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrNoPassword        = errors.New("account has no local password")
+	ErrEmailRequired     = errors.New("email cannot be empty")
+	ErrDisplayNameLength = errors.New("display name is too long")
+	ErrLastAdmin         = errors.New("the only admin of an organization with other members cannot delete their account")
+)
+
+// ProfileUpdate lists the profile fields to change; nil fields are kept
+type ProfileUpdate struct {
+	Email       *string
+	DisplayName *string
+	NewPassword *string
+}
+
+// DeletionReceipt records what was removed with an account
+type DeletionReceipt struct {
+	UserID         string    `json:"user_id"`
+	Username       string    `json:"username"`
+	DeletedAt      time.Time `json:"deleted_at"`
+	ResumesDeleted int       `json:"resumes_deleted"`
+	FilesDeleted   int       `json:"files_deleted"`
+	APIKeysRevoked int       `json:"api_keys_revoked"`
+}
+
+// CheckPassword verifies a user's current password. It returns
+// ErrNoPassword for accounts that only sign in through SSO.
+func CheckPassword(userID, password string) error {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if dbUser == nil {
+		return errors.New("user not found")
+	}
+	if dbUser.Password == "" {
+		return ErrNoPassword
+	}
+
+	user := toUser(dbUser)
+	user.Password = dbUser.Password
+	if !VerifyPassword(user, password) {
+		return ErrIncorrectPassword
+	}
+	return nil
+}
+
+// UpdateProfile changes a user's own profile. A new email address has to
+// be verified again, and a new password ends every existing session.
+// It returns a *ConflictError if the email is already taken.
+func UpdateProfile(userID string, update ProfileUpdate) (*User, error) {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if dbUser == nil {
+		return nil, errors.New("user not found")
+	}
+
+	if update.DisplayName != nil {
+		displayName := strings.TrimSpace(*update.DisplayName)
+		if len(displayName) > MaxDisplayNameLength {
+			return nil, ErrDisplayNameLength
+		}
+		dbUser.DisplayName = displayName
+	}
+	if update.Email != nil {
+		email := strings.TrimSpace(*update.Email)
+		if email == "" {
+			return nil, ErrEmailRequired
+		}
+		if !strings.EqualFold(email, dbUser.Email) {
+			dbUser.EmailVerified = false
+		}
+		dbUser.Email = email
+	}
+	if update.NewPassword != nil {
+		hashedPassword, err := hashPassword(*update.NewPassword)
+		if err != nil {
+			return nil, err
+		}
+		dbUser.Password = hashedPassword
+		dbUser.PasswordChangedAt = time.Now()
+	}
+
+	if err := store.SaveUser(dbUser); err != nil {
+		return nil, err
+	}
+	return toUser(dbUser), nil
+}
+
+// DeleteAccount deletes a user along with their resumes, uploaded files
+// and personal jobs, and revokes their API keys. The only admin of an organization that has
+// other members must hand over the role first.
+func DeleteAccount(userID string) (*DeletionReceipt, error) {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if dbUser == nil {
+		return nil, errors.New("user not found")
+	}
+
+	// Do not leave an organization without an admin
+	if dbUser.OrgID != "" && dbUser.Role == RoleAdmin {
+		members, err := store.GetUsersByOrgID(dbUser.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		otherAdmin, otherMembers := false, false
+		for _, member := range members {
+			if member.ID == dbUser.ID {
+				continue
+			}
+			otherMembers = true
+			if member.Role == RoleAdmin {
+				otherAdmin = true
+			}
+		}
+		if otherMembers && !otherAdmin {
+			return nil, ErrLastAdmin
+		}
+	}
+
+	receipt := &DeletionReceipt{UserID: dbUser.ID, Username: dbUser.Username}
+
+	// Resumes and their files, including any past retention
+	resumes, err := store.GetResumesByUserID(dbUser.OrgID, dbUser.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, resume := range resumes {
+		if err := store.DeleteResume(dbUser.OrgID, resume.ID); err != nil {
+			return nil, err
+		}
+		searchIndex.remove(resume.ID)
+		receipt.ResumesDeleted++
+		removed, err := removeResumeFile(resume)
+		if err != nil {
+			log.Printf("Error removing file of resume %s: %v", resume.ID, err)
+		}
+		if removed {
+			receipt.FilesDeleted++
+		}
+	}
+
+	// Personal jobs; an organization's jobs stay with it
+	if dbUser.OrgID == "" {
+		jobs, err := store.GetJobs("", dbUser.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range jobs {
+			if err := store.DeleteJob("", job.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// API keys stop working with the owner gone; revoke them for the record
+	keys, err := store.GetAPIKeys(dbUser.OrgID, dbUser.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if key.RevokedAt != nil {
+			continue
+		}
+		if err := RevokeAPIKey(key.ID); err != nil {
+			return nil, err
+		}
+		receipt.APIKeysRevoked++
+	}
+
+	if err := store.DeleteUser(dbUser.ID); err != nil {
+		return nil, err
+	}
+	receipt.DeletedAt = time.Now()
+	return receipt, nil
+}
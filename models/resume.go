@@ -1,16 +1,37 @@
 package models
 
 import (
+	"errors"
+	"log"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"webscrapper/database"
 )
 
+// Limits on resume metadata, in bytes
+const (
+	MaxResumeTitleLength   = 200
+	MaxCandidateNameLength = 100
+	MaxTagLength           = 50
+	MaxResumeTags          = 20
+)
+
+// Resume metadata errors
+var (
+	ErrResumeTitleLength   = errors.New("title is too long")
+	ErrCandidateNameLength = errors.New("candidate name is too long")
+	ErrTagLength           = errors.New("tags must be between 1 and 50 bytes")
+	ErrTooManyTags         = errors.New("a resume can have at most 20 tags")
+)
+
 // Resume represents a parsed resume in the system
 type Resume struct {
 	ID         string    `json:"id"`
 	UserID     string    `json:"user_id"`
+	OrgID      string    `json:"org_id,omitempty"`
 	Filename   string    `json:"filename"`
 	Content    string    `json:"content"`
 	Keywords   []string  `json:"keywords"`
@@ -18,10 +39,47 @@ type Resume struct {
 	Education  []string  `json:"education"`
 	Experience []string  `json:"experience"`
 	UploadedAt time.Time `json:"uploaded_at"`
+	FilePath   string    `json:"-"` // Server-side path of the uploaded file
+
+	// Metadata editable after upload
+	Title         string   `json:"title,omitempty"`
+	CandidateName string   `json:"candidate_name,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// toResume converts a database resume
+func toResume(dbResume *database.Resume) *Resume {
+	return &Resume{
+		ID:         dbResume.ID,
+		UserID:     dbResume.UserID,
+		OrgID:      dbResume.OrgID,
+		Filename:   dbResume.Filename,
+		Content:    dbResume.Content,
+		Keywords:   dbResume.Keywords,
+		Skills:     dbResume.Skills,
+		Education:  dbResume.Education,
+		Experience: dbResume.Experience,
+		UploadedAt: dbResume.UploadedAt,
+		FilePath:   dbResume.FilePath,
+
+		Title:         dbResume.Title,
+		CandidateName: dbResume.CandidateName,
+		Tags:          dbResume.Tags,
+	}
+}
+
+// toResumes converts a list of database resumes
+func toResumes(dbResumes []*database.Resume) []*Resume {
+	var resumes []*Resume
+	for _, dbResume := range dbResumes {
+		resumes = append(resumes, toResume(dbResume))
+	}
+	return resumes
 }
 
-// SaveResume saves a resume to the database
-func SaveResume(userID, filename, content string, keywords, skills, education, experience []string) (*Resume, error) {
+// SaveResume saves a resume to the database, owned by the uploader's organization.
+// filePath is where the uploaded file was stored.
+func SaveResume(userID, orgID, filename, filePath, content string, keywords, skills, education, experience []string) (*Resume, error) {
 	// Generate a unique ID
 	id := uuid.New().String()
 	
@@ -29,6 +87,7 @@ func SaveResume(userID, filename, content string, keywords, skills, education, e
 	resume := &database.Resume{
 		ID:         id,
 		UserID:     userID,
+		OrgID:      orgID,
 		Filename:   filename,
 		Content:    content,
 		Keywords:   keywords,
@@ -36,68 +95,160 @@ func SaveResume(userID, filename, content string, keywords, skills, education, e
 		Education:  education,
 		Experience: experience,
 		UploadedAt: time.Now(),
+		FilePath:   filePath,
 	}
 	
-	// Save to in-memory database
-	err := database.SaveResume(resume)
+	// Save to the database
+	err := store.SaveResume(resume)
 	if err != nil {
 		return nil, err
 	}
+	searchIndex.add(resume)
 	
-	return &Resume{
-		ID:         id,
-		UserID:     userID,
-		Filename:   filename,
-		Content:    content,
-		Keywords:   keywords,
-		Skills:     skills,
-		Education:  education,
-		Experience: experience,
-		UploadedAt: time.Now(),
-	}, nil
+	return toResume(resume), nil
 }
 
-// GetResumeByID retrieves a resume by its ID
-func GetResumeByID(id string) (*Resume, error) {
-	dbResume, err := database.GetResumeByID(id)
+// GetResumeByID retrieves a resume by its ID within an organization.
+// Resumes past the organization's retention period are treated as missing.
+func GetResumeByID(orgID, id string) (*Resume, error) {
+	dbResume, err := store.GetResumeByID(orgID, id)
 	if err != nil || dbResume == nil {
 		return nil, err
 	}
+	if dbResume.UploadedAt.Before(retentionCutoff(orgID)) {
+		return nil, nil
+	}
 	
-	return &Resume{
-		ID:         dbResume.ID,
-		UserID:     dbResume.UserID,
-		Filename:   dbResume.Filename,
-		Content:    dbResume.Content,
-		Keywords:   dbResume.Keywords,
-		Skills:     dbResume.Skills,
-		Education:  dbResume.Education,
-		Experience: dbResume.Experience,
-		UploadedAt: dbResume.UploadedAt,
-	}, nil
+	return toResume(dbResume), nil
 }
 
-// GetResumesByUserID retrieves all resumes for a specific user
-func GetResumesByUserID(userID string) ([]*Resume, error) {
-	dbResumes, err := database.GetResumesByUserID(userID)
+// GetResumesByUserID retrieves all resumes for a specific user within an organization
+func GetResumesByUserID(orgID, userID string) ([]*Resume, error) {
+	dbResumes, err := store.GetResumesByUserID(orgID, userID)
 	if err != nil {
 		return nil, err
 	}
 	
-	var resumes []*Resume
-	for _, dbResume := range dbResumes {
-		resumes = append(resumes, &Resume{
-			ID:         dbResume.ID,
-			UserID:     dbResume.UserID,
-			Filename:   dbResume.Filename,
-			Content:    dbResume.Content,
-			Keywords:   dbResume.Keywords,
-			Skills:     dbResume.Skills,
-			Education:  dbResume.Education,
-			Experience: dbResume.Experience,
-			UploadedAt: dbResume.UploadedAt,
-		})
+	return toResumes(withinRetention(orgID, dbResumes)), nil
+}
+
+// GetResumesByOrgID retrieves all resumes owned by an organization
+func GetResumesByOrgID(orgID string) ([]*Resume, error) {
+	dbResumes, err := store.GetResumesByOrgID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	
+	return toResumes(withinRetention(orgID, dbResumes)), nil
+}
+
+// ResumeUpdate lists the resume metadata to change; nil fields are kept
+type ResumeUpdate struct {
+	Title         *string
+	CandidateName *string
+	Tags          *[]string
+}
+
+// UpdateResume changes a resume's title, candidate name or tags. It returns
+// nil when the resume does not exist in the organization.
+func UpdateResume(orgID, id string, update ResumeUpdate) (*Resume, error) {
+	dbResume, err := store.GetResumeByID(orgID, id)
+	if err != nil || dbResume == nil {
+		return nil, err
+	}
+	
+	// Check and apply each field
+	if update.Title != nil {
+		title := strings.TrimSpace(*update.Title)
+		if len(title) > MaxResumeTitleLength {
+			return nil, ErrResumeTitleLength
+		}
+		dbResume.Title = title
+	}
+	if update.CandidateName != nil {
+		name := strings.TrimSpace(*update.CandidateName)
+		if len(name) > MaxCandidateNameLength {
+			return nil, ErrCandidateNameLength
+		}
+		dbResume.CandidateName = name
+	}
+	if update.Tags != nil {
+		tags, err := normalizeTags(*update.Tags)
+		if err != nil {
+			return nil, err
+		}
+		dbResume.Tags = tags
 	}
 	
-	return resumes, nil
+	if err := store.SaveResume(dbResume); err != nil {
+		return nil, err
+	}
+	searchIndex.add(dbResume)
+	return toResume(dbResume), nil
+}
+
+// normalizeTags trims and lowercases tags and drops duplicates
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > MaxTagLength {
+			return nil, ErrTagLength
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxResumeTags {
+		return nil, ErrTooManyTags
+	}
+	return normalized, nil
+}
+
+// DeleteResume deletes a resume and its uploaded file. A file that cannot be
+// removed is logged, since the resume itself is already gone.
+func DeleteResume(orgID, id string) error {
+	dbResume, err := store.GetResumeByID(orgID, id)
+	if err != nil || dbResume == nil {
+		return err
+	}
+	if err := store.DeleteResume(orgID, id); err != nil {
+		return err
+	}
+	searchIndex.remove(id)
+	if _, err := removeResumeFile(dbResume); err != nil {
+		log.Printf("Error removing file of deleted resume %s: %v", id, err)
+	}
+	return nil
+}
+
+// removeResumeFile deletes a resume's uploaded file. It reports whether a
+// file was removed; a file that is already gone is not an error.
+func removeResumeFile(dbResume *database.Resume) (bool, error) {
+	if dbResume.FilePath == "" {
+		return false, nil
+	}
+	err := os.Remove(dbResume.FilePath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// withinRetention drops resumes past the organization's retention period
+func withinRetention(orgID string, dbResumes []*database.Resume) []*database.Resume {
+	cutoff := retentionCutoff(orgID)
+	if cutoff.IsZero() {
+		return dbResumes
+	}
+	var kept []*database.Resume
+	for _, dbResume := range dbResumes {
+		if !dbResume.UploadedAt.Before(cutoff) {
+			kept = append(kept, dbResume)
+		}
+	}
+	return kept
 }
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"webscrapper/textutil"
+)
+
+// Resume list sort orders
+const (
+	SortUploadedAt = "uploaded_at"
+	SortFilename   = "filename"
+	SortScore      = "score"
+)
+
+// ResumeQuery filters, orders and pages a list of resumes
+type ResumeQuery struct {
+	// Skills the resumes must have; all of them unless MatchAnySkill
+	Skills        []string
+	MatchAnySkill bool
+	Tags          []string // Tags the resumes must all have
+
+	UploadedAfter  time.Time
+	UploadedBefore time.Time
+
+	Sort       string // One of the Sort constants, SortUploadedAt if empty
+	Descending bool
+
+	After *ResumeCursor // Position of the last resume of the previous page
+	Limit int
+}
+
+// ResumeCursor is the position of a resume in a sorted list
+type ResumeCursor struct {
+	Sort       string    `json:"s"`
+	Descending bool      `json:"d,omitempty"`
+	ID         string    `json:"i"`
+	UploadedAt time.Time `json:"u"`
+	Filename   string    `json:"f,omitempty"`
+	Score      float64   `json:"sc,omitempty"`
+}
+
+// ScoredResume is a resume with its score for a query: the percentage of
+// the queried skills it has
+type ScoredResume struct {
+	*Resume
+	Score float64
+}
+
+// QueryResumes applies query to resumes. It returns one page and the cursor
+// of the page's last resume, which is nil when no resumes follow.
+func QueryResumes(resumes []*Resume, query ResumeQuery) ([]*ScoredResume, *ResumeCursor) {
+	sortBy := query.Sort
+	if sortBy == "" {
+		sortBy = SortUploadedAt
+	}
+	skills := skillSet(query.Skills)
+	tags := lowerSet(query.Tags)
+
+	// Filter and score
+	var matched []*ScoredResume
+	for _, resume := range resumes {
+		if !query.UploadedAfter.IsZero() && !resume.UploadedAt.After(query.UploadedAfter) {
+			continue
+		}
+		if !query.UploadedBefore.IsZero() && !resume.UploadedAt.Before(query.UploadedBefore) {
+			continue
+		}
+		if countIn(resume.Tags, tags) < len(tags) {
+			continue
+		}
+		score := 0.0
+		if len(skills) > 0 {
+			found := countIn(canonicalSkills(resume.Skills), skills)
+			if found == 0 || (!query.MatchAnySkill && found < len(skills)) {
+				continue
+			}
+			score = 100 * float64(found) / float64(len(skills))
+		}
+		matched = append(matched, &ScoredResume{Resume: resume, Score: score})
+	}
+
+	// Sort, breaking ties by ID so pages never overlap
+	less := func(a, b *ResumeCursor) bool {
+		switch sortBy {
+		case SortFilename:
+			if x, y := strings.ToLower(a.Filename), strings.ToLower(b.Filename); x != y {
+				return x < y != query.Descending
+			}
+		case SortScore:
+			if a.Score != b.Score {
+				return a.Score < b.Score != query.Descending
+			}
+			// Equal scores list newest first
+			if !a.UploadedAt.Equal(b.UploadedAt) {
+				return a.UploadedAt.After(b.UploadedAt)
+			}
+		default:
+			if !a.UploadedAt.Equal(b.UploadedAt) {
+				return a.UploadedAt.Before(b.UploadedAt) != query.Descending
+			}
+		}
+		return a.ID < b.ID
+	}
+	cursor := func(resume *ScoredResume) *ResumeCursor {
+		return &ResumeCursor{
+			Sort:       sortBy,
+			Descending: query.Descending,
+			ID:         resume.ID,
+			UploadedAt: resume.UploadedAt,
+			Filename:   resume.Filename,
+			Score:      resume.Score,
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return less(cursor(matched[i]), cursor(matched[j]))
+	})
+
+	// Skip to the requested page
+	if query.After != nil {
+		start := sort.Search(len(matched), func(i int) bool {
+			return less(query.After, cursor(matched[i]))
+		})
+		matched = matched[start:]
+	}
+	if query.Limit <= 0 || len(matched) <= query.Limit {
+		return matched, nil
+	}
+	page := matched[:query.Limit]
+	return page, cursor(page[len(page)-1])
+}
+
+// lowerSet returns the distinct lowercased non-empty values
+func lowerSet(values []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			set[value] = true
+		}
+	}
+	return set
+}
+
+// skillSet returns the distinct skills under their canonical names, so
+// golang finds resumes with go and k8s those with kubernetes
+func skillSet(values []string) map[string]bool {
+	return lowerSet(canonicalSkills(values))
+}
+
+// canonicalSkills returns skills under their canonical names
+func canonicalSkills(skills []string) []string {
+	canonical := make([]string, len(skills))
+	for i, skill := range skills {
+		canonical[i] = textutil.CanonicalSkill(skill)
+	}
+	return canonical
+}
+
+// countIn counts the distinct values that are in set, ignoring case
+func countIn(values []string, set map[string]bool) int {
+	if len(set) == 0 {
+		return 0
+	}
+	seen := make(map[string]bool)
+	for _, value := range values {
+		value = strings.ToLower(value)
+		if set[value] && !seen[value] {
+			seen[value] = true
+		}
+	}
+	return len(seen)
+}
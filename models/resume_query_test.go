@@ -0,0 +1,50 @@
+package models
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestQueryResumesSkillAliases(t *testing.T) {
+	resumes := []*Resume{
+		{ID: "go", Skills: []string{"go", "docker"}},
+		{ID: "golang", Skills: []string{"Golang"}},
+		{ID: "k8s", Skills: []string{"kubernetes", "postgresql"}},
+		{ID: "none", Skills: []string{"java"}},
+	}
+
+	tests := []struct {
+		name  string
+		query ResumeQuery
+		want  []string
+	}{
+		{"alias in filter", ResumeQuery{Skills: []string{"golang"}}, []string{"go", "golang"}},
+		{"canonical in filter", ResumeQuery{Skills: []string{"Go"}}, []string{"go", "golang"}},
+		{"k8s", ResumeQuery{Skills: []string{"k8s"}}, []string{"k8s"}},
+		{"all of several aliases", ResumeQuery{Skills: []string{"k8s", "postgres"}}, []string{"k8s"}},
+		{"any of several", ResumeQuery{Skills: []string{"golang", "k8s"}, MatchAnySkill: true}, []string{"go", "golang", "k8s"}},
+		// Two names of one skill are one requirement
+		{"same skill twice", ResumeQuery{Skills: []string{"go", "golang"}}, []string{"go", "golang"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, _ := QueryResumes(resumes, tt.query)
+			got := []string{}
+			for _, resume := range page {
+				got = append(got, resume.ID)
+				if resume.Score != 100 && !tt.query.MatchAnySkill {
+					t.Errorf("%s scored %v, want 100", resume.ID, resume.Score)
+				}
+			}
+			sort.Strings(got)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,485 @@
+package models
+
+import (
+	"html"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"webscrapper/database"
+	"webscrapper/textutil"
+)
+
+// searchFields are the indexed resume fields and the weight of a match in
+// each. Queries scope a term to one of them with field:term.
+var searchFields = map[string]float64{
+	"content":    1,
+	"skill":      2,
+	"experience": 1.5,
+	"education":  1,
+}
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// spaceRun matches whitespace collapsed in snippets
+var spaceRun = regexp.MustCompile(`\s+`)
+
+// fieldGap separates the values of a multi-valued field so phrases do not
+// match across two skills or two experience lines
+const fieldGap = 100
+
+// Snippet size, in tokens
+const (
+	snippetLength = 30
+	snippetLead   = 8
+)
+
+// SearchHit is a resume matching a search
+type SearchHit struct {
+	ResumeID      string    `json:"resume_id"`
+	UserID        string    `json:"user_id"`
+	Filename      string    `json:"filename"`
+	Title         string    `json:"title,omitempty"`
+	CandidateName string    `json:"candidate_name,omitempty"`
+	UploadedAt    time.Time `json:"uploaded_at"`
+	Skills        []string  `json:"skills"`
+	Score         float64   `json:"score"`
+	MatchedFields []string  `json:"matched_fields"`
+
+	// Snippet is an HTML-escaped excerpt of the text with the matched
+	// words wrapped in <mark> tags
+	Snippet string `json:"snippet"`
+}
+
+// SearchResults is one page of search hits, best first
+type SearchResults struct {
+	Total int          `json:"total"`
+	Hits  []*SearchHit `json:"results"`
+}
+
+// searchToken is a word of a text and its byte offsets
+type searchToken struct {
+	term       string
+	start, end int
+}
+
+// searchDoc is what the index keeps about a resume
+type searchDoc struct {
+	orgID      string
+	userID     string
+	uploadedAt time.Time
+	lengths    map[string]int      // Tokens per field
+	terms      map[string][]string // Distinct terms per field, for removal
+}
+
+// resumeIndex is an inverted index over resume fields. It is built from
+// the store on first use and kept up to date as resumes change.
+type resumeIndex struct {
+	mutex    sync.RWMutex
+	built    bool
+	docs     map[string]*searchDoc
+	postings map[string]map[string]map[string][]int // field, term, resume ID: positions
+}
+
+// searchIndex is the index used by SearchResumes
+var searchIndex = &resumeIndex{}
+
+// reset empties the index so it is rebuilt from the store on next use
+func (ix *resumeIndex) reset() {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+	ix.built = false
+	ix.docs = nil
+	ix.postings = nil
+}
+
+// ensureBuilt loads every resume of the store into the index the first
+// time it is needed
+func (ix *resumeIndex) ensureBuilt() error {
+	ix.mutex.RLock()
+	built := ix.built
+	ix.mutex.RUnlock()
+	if built {
+		return nil
+	}
+
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+	if ix.built {
+		return nil
+	}
+	ix.docs = make(map[string]*searchDoc)
+	ix.postings = make(map[string]map[string]map[string][]int)
+
+	// Resumes of every organization and of users without one
+	orgs, err := store.GetOrganizations()
+	if err != nil {
+		return err
+	}
+	orgIDs := []string{""}
+	for _, org := range orgs {
+		orgIDs = append(orgIDs, org.ID)
+	}
+	for _, orgID := range orgIDs {
+		resumes, err := store.GetResumesByOrgID(orgID)
+		if err != nil {
+			return err
+		}
+		for _, resume := range resumes {
+			ix.addLocked(resume)
+		}
+	}
+	ix.built = true
+	return nil
+}
+
+// add indexes a saved resume, replacing an earlier version
+func (ix *resumeIndex) add(resume *database.Resume) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+	if ix.built {
+		ix.addLocked(resume)
+	}
+}
+
+// remove drops a deleted resume from the index
+func (ix *resumeIndex) remove(id string) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+	if ix.built {
+		ix.removeLocked(id)
+	}
+}
+
+func (ix *resumeIndex) addLocked(resume *database.Resume) {
+	ix.removeLocked(resume.ID)
+	doc := &searchDoc{
+		orgID:      resume.OrgID,
+		userID:     resume.UserID,
+		uploadedAt: resume.UploadedAt,
+		lengths:    make(map[string]int),
+		terms:      make(map[string][]string),
+	}
+	values := map[string][]string{
+		"content":    {resume.Content},
+		"skill":      canonicalSkills(resume.Skills),
+		"experience": resume.Experience,
+		"education":  resume.Education,
+	}
+	for field, texts := range values {
+		if ix.postings[field] == nil {
+			ix.postings[field] = make(map[string]map[string][]int)
+		}
+		position := 0
+		for _, text := range texts {
+			for _, token := range tokenize(text) {
+				postings := ix.postings[field][token.term]
+				if postings == nil {
+					postings = make(map[string][]int)
+					ix.postings[field][token.term] = postings
+				}
+				if postings[resume.ID] == nil {
+					doc.terms[field] = append(doc.terms[field], token.term)
+				}
+				postings[resume.ID] = append(postings[resume.ID], position)
+				position++
+				doc.lengths[field]++
+			}
+			position += fieldGap
+		}
+	}
+	ix.docs[resume.ID] = doc
+}
+
+func (ix *resumeIndex) removeLocked(id string) {
+	doc, exists := ix.docs[id]
+	if !exists {
+		return
+	}
+	for field, terms := range doc.terms {
+		for _, term := range terms {
+			delete(ix.postings[field][term], id)
+			if len(ix.postings[field][term]) == 0 {
+				delete(ix.postings[field], term)
+			}
+		}
+	}
+	delete(ix.docs, id)
+}
+
+// frequencies counts how often a term or phrase occurs in field, per
+// resume of universe
+func (ix *resumeIndex) frequencies(field string, terms []string, universe map[string]bool) map[string]int {
+	counts := make(map[string]int)
+	for id, positions := range ix.postings[field][terms[0]] {
+		if !universe[id] {
+			continue
+		}
+		count := 0
+		for _, start := range positions {
+			matched := true
+			for offset, term := range terms[1:] {
+				next := ix.postings[field][term][id]
+				i := sort.SearchInts(next, start+offset+1)
+				if i == len(next) || next[i] != start+offset+1 {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				count++
+			}
+		}
+		if count > 0 {
+			counts[id] = count
+		}
+	}
+	return counts
+}
+
+// match returns the resumes of universe matching node
+func (ix *resumeIndex) match(node *queryNode, universe map[string]bool) map[string]bool {
+	matched := make(map[string]bool)
+	switch node.op {
+	case opTerm:
+		for _, field := range node.fields() {
+			for id := range ix.frequencies(field, node.terms, universe) {
+				matched[id] = true
+			}
+		}
+	case opAnd:
+		for i, child := range node.children {
+			childMatched := ix.match(child, universe)
+			if i == 0 {
+				matched = childMatched
+				continue
+			}
+			for id := range matched {
+				if !childMatched[id] {
+					delete(matched, id)
+				}
+			}
+		}
+	case opOr:
+		for _, child := range node.children {
+			for id := range ix.match(child, universe) {
+				matched[id] = true
+			}
+		}
+	case opNot:
+		excluded := ix.match(node.children[0], universe)
+		for id := range universe {
+			if !excluded[id] {
+				matched[id] = true
+			}
+		}
+	}
+	return matched
+}
+
+// rankedResume is a matching resume and its BM25 score
+type rankedResume struct {
+	id         string
+	score      float64
+	fields     map[string]bool
+	uploadedAt time.Time
+}
+
+// search returns the resumes of universe matching root, best first
+func (ix *resumeIndex) search(root *queryNode, universe map[string]bool) []*rankedResume {
+	matched := ix.match(root, universe)
+	ranked := make(map[string]*rankedResume)
+	for id := range matched {
+		ranked[id] = &rankedResume{id: id, fields: make(map[string]bool), uploadedAt: ix.docs[id].uploadedAt}
+	}
+
+	// Average field lengths over the searched resumes
+	averages := make(map[string]float64)
+	for id := range universe {
+		for field, length := range ix.docs[id].lengths {
+			averages[field] += float64(length)
+		}
+	}
+	for field := range averages {
+		averages[field] /= float64(len(universe))
+	}
+
+	// Sum the BM25 score of every term that is not negated
+	total := float64(len(universe))
+	for _, node := range root.positiveTerms() {
+		for _, field := range node.fields() {
+			counts := ix.frequencies(field, node.terms, universe)
+			if len(counts) == 0 {
+				continue
+			}
+			df := float64(len(counts))
+			idf := math.Log(1 + (total-df+0.5)/(df+0.5))
+			for id, count := range counts {
+				hit, ok := ranked[id]
+				if !ok {
+					continue
+				}
+				tf := float64(count)
+				norm := 1 - bm25B + bm25B*float64(ix.docs[id].lengths[field])/averages[field]
+				hit.score += searchFields[field] * idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+				hit.fields[field] = true
+			}
+		}
+	}
+
+	// Best score first, then newest
+	var results []*rankedResume
+	for _, hit := range ranked {
+		results = append(results, hit)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if !a.uploadedAt.Equal(b.uploadedAt) {
+			return a.uploadedAt.After(b.uploadedAt)
+		}
+		return a.id < b.id
+	})
+	return results
+}
+
+// SearchResumes runs a search query over an organization's resumes, or
+// only the given user's when userID is set, and returns the page starting
+// at offset. It returns a *QueryError if the query is malformed.
+func SearchResumes(orgID, userID, query string, offset, limit int) (*SearchResults, error) {
+	root, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if err := searchIndex.ensureBuilt(); err != nil {
+		return nil, err
+	}
+
+	// Rank the resumes the caller may search
+	searchIndex.mutex.RLock()
+	cutoff := retentionCutoff(orgID)
+	universe := make(map[string]bool)
+	for id, doc := range searchIndex.docs {
+		if doc.orgID == orgID && (userID == "" || doc.userID == userID) && !doc.uploadedAt.Before(cutoff) {
+			universe[id] = true
+		}
+	}
+	ranked := searchIndex.search(root, universe)
+	searchIndex.mutex.RUnlock()
+
+	results := &SearchResults{Total: len(ranked), Hits: []*SearchHit{}}
+	if offset >= len(ranked) {
+		return results, nil
+	}
+	ranked = ranked[offset:]
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	// Load the page and cut snippets around the matched words
+	highlight := make(map[string]bool)
+	for _, node := range root.positiveTerms() {
+		if node.field == "" || node.field == "content" {
+			for _, term := range node.terms {
+				highlight[term] = true
+			}
+		}
+	}
+	for _, hit := range ranked {
+		dbResume, err := store.GetResumeByID(orgID, hit.id)
+		if err != nil {
+			return nil, err
+		}
+		if dbResume == nil {
+			continue // Deleted since it was ranked
+		}
+		var fields []string
+		for field := range hit.fields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		results.Hits = append(results.Hits, &SearchHit{
+			ResumeID:      dbResume.ID,
+			UserID:        dbResume.UserID,
+			Filename:      dbResume.Filename,
+			Title:         dbResume.Title,
+			CandidateName: dbResume.CandidateName,
+			UploadedAt:    dbResume.UploadedAt,
+			Skills:        dbResume.Skills,
+			Score:         math.Round(hit.score*1000) / 1000,
+			MatchedFields: fields,
+			Snippet:       snippet(dbResume.Content, highlight),
+		})
+	}
+	return results, nil
+}
+
+// tokenize splits text into lowercase words, see textutil.Tokenize
+func tokenize(text string) []searchToken {
+	var tokens []searchToken
+	for _, token := range textutil.Tokenize(text) {
+		tokens = append(tokens, searchToken{term: strings.ToLower(token.Text), start: token.Start, end: token.End})
+	}
+	return tokens
+}
+
+// snippet cuts the part of text with the most highlighted words and marks
+// them. The result is HTML-escaped.
+func snippet(text string, highlight map[string]bool) string {
+	tokens := tokenize(text)
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	// Pick the window holding the most highlighted words
+	best, bestCount := 0, 0
+	for i, token := range tokens {
+		if !highlight[token.term] {
+			continue
+		}
+		start := i - snippetLead
+		if start < 0 {
+			start = 0
+		}
+		count := 0
+		for _, other := range tokens[start:min(start+snippetLength, len(tokens))] {
+			if highlight[other.term] {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = start, count
+		}
+	}
+	end := min(best+snippetLength, len(tokens))
+
+	// Escape the text between words, marking the highlighted ones
+	var b strings.Builder
+	if best > 0 {
+		b.WriteString("… ")
+	}
+	for i, token := range tokens[best:end] {
+		if i > 0 {
+			gap := text[tokens[best+i-1].end:token.start]
+			b.WriteString(html.EscapeString(spaceRun.ReplaceAllString(gap, " ")))
+		}
+		word := html.EscapeString(text[token.start:token.end])
+		if highlight[token.term] {
+			word = "<mark>" + word + "</mark>"
+		}
+		b.WriteString(word)
+	}
+	if end < len(tokens) {
+		b.WriteString(" …")
+	}
+	return b.String()
+}
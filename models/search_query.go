@@ -0,0 +1,325 @@
+package models
+
+import (
+	"strings"
+
+	"webscrapper/textutil"
+)
+
+// Search query limits
+const (
+	MaxSearchQueryLength = 500
+	maxSearchTerms       = 32
+	maxSearchDepth       = 16
+)
+
+// QueryError reports a malformed search query
+type QueryError struct {
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return "invalid query: " + e.Message
+}
+
+// Query node operators
+const (
+	opTerm = "term"
+	opAnd  = "and"
+	opOr   = "or"
+	opNot  = "not"
+)
+
+// queryNode is a parsed search query. Term nodes hold one word or the
+// words of a phrase; field is empty when the term may match any field.
+type queryNode struct {
+	op       string
+	field    string
+	terms    []string
+	children []*queryNode
+}
+
+// fields returns the indexed fields a term node searches
+func (n *queryNode) fields() []string {
+	if n.field != "" {
+		return []string{n.field}
+	}
+	var fields []string
+	for field := range searchFields {
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// positiveTerms returns the term nodes that are not negated; these are
+// scored and highlighted
+func (n *queryNode) positiveTerms() []*queryNode {
+	switch n.op {
+	case opTerm:
+		return []*queryNode{n}
+	case opNot:
+		return nil
+	}
+	var terms []*queryNode
+	for _, child := range n.children {
+		terms = append(terms, child.positiveTerms()...)
+	}
+	return terms
+}
+
+// Query token kinds
+const (
+	tokenWord = iota
+	tokenPhrase
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+// queryToken is a lexical element of a search query
+type queryToken struct {
+	kind  int
+	field string
+	text  string
+}
+
+// searchFieldNames maps the field prefixes accepted in queries
+var searchFieldNames = map[string]string{
+	"content":    "content",
+	"text":       "content",
+	"skill":      "skill",
+	"skills":     "skill",
+	"experience": "experience",
+	"education":  "education",
+}
+
+// parseSearchQuery parses a query such as
+//
+//	kubernetes AND (fintech OR banking) -intern skill:"machine learning"
+//
+// Words next to each other must all match. AND, OR and NOT are operators
+// only in capitals; NOT binds tightest, then AND, then OR. A leading - also
+// negates, and field: limits a word or quoted phrase to one field.
+func parseSearchQuery(query string) (*queryNode, error) {
+	if len(query) > MaxSearchQueryLength {
+		return nil, &QueryError{Message: "query is too long"}
+	}
+	tokens, err := lexSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, &QueryError{Message: "query has no search terms"}
+	}
+	parser := &queryParser{tokens: tokens}
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, &QueryError{Message: "unexpected )"}
+	}
+	if root == nil {
+		return nil, &QueryError{Message: "query has no search terms"}
+	}
+	return root, nil
+}
+
+// lexSearchQuery splits a query into tokens
+func lexSearchQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, queryToken{kind: tokenOpen})
+			i++
+		case c == ')':
+			tokens = append(tokens, queryToken{kind: tokenClose})
+			i++
+		case c == '-' && i+1 < len(query) && !strings.ContainsRune(" \t\n\r)", rune(query[i+1])):
+			tokens = append(tokens, queryToken{kind: tokenNot})
+			i++
+		case c == '"':
+			phrase, next, err := readPhrase(query, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{kind: tokenPhrase, text: phrase})
+			i = next
+		default:
+			start := i
+			for i < len(query) && !strings.ContainsRune(" \t\n\r()\"", rune(query[i])) {
+				i++
+			}
+			word := query[start:i]
+			switch word {
+			case "AND":
+				tokens = append(tokens, queryToken{kind: tokenAnd})
+				continue
+			case "OR":
+				tokens = append(tokens, queryToken{kind: tokenOr})
+				continue
+			case "NOT":
+				tokens = append(tokens, queryToken{kind: tokenNot})
+				continue
+			}
+
+			// field:word or field:"phrase"
+			prefix, value, scoped := strings.Cut(word, ":")
+			if !scoped || prefix == "" {
+				tokens = append(tokens, queryToken{kind: tokenWord, text: word})
+				continue
+			}
+			field, known := searchFieldNames[strings.ToLower(prefix)]
+			if !known {
+				return nil, &QueryError{Message: "unknown field " + prefix + ": use skill, education, experience or content"}
+			}
+			if value == "" && i < len(query) && query[i] == '"' {
+				phrase, next, err := readPhrase(query, i)
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, queryToken{kind: tokenPhrase, field: field, text: phrase})
+				i = next
+				continue
+			}
+			tokens = append(tokens, queryToken{kind: tokenWord, field: field, text: value})
+		}
+	}
+	return tokens, nil
+}
+
+// readPhrase reads the quoted phrase starting at query[start] and returns
+// it with the index after the closing quote
+func readPhrase(query string, start int) (string, int, error) {
+	end := strings.IndexByte(query[start+1:], '"')
+	if end < 0 {
+		return "", 0, &QueryError{Message: "unterminated quote"}
+	}
+	return query[start+1 : start+1+end], start + end + 2, nil
+}
+
+// queryParser builds a query tree by recursive descent
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	depth  int
+	terms  int
+}
+
+// peek returns the kind of the next token, or -1 at the end
+func (p *queryParser) peek() int {
+	if p.pos >= len(p.tokens) {
+		return -1
+	}
+	return p.tokens[p.pos].kind
+}
+
+// parseOr parses and-expressions separated by OR
+func (p *queryParser) parseOr() (*queryNode, error) {
+	return p.parseList(opOr, p.parseAnd, func() bool {
+		if p.peek() == tokenOr {
+			p.pos++
+			return true
+		}
+		return false
+	})
+}
+
+// parseAnd parses unary expressions separated by AND or nothing
+func (p *queryParser) parseAnd() (*queryNode, error) {
+	return p.parseList(opAnd, p.parseUnary, func() bool {
+		switch p.peek() {
+		case tokenAnd:
+			p.pos++
+			return true
+		case tokenWord, tokenPhrase, tokenNot, tokenOpen:
+			return true
+		}
+		return false
+	})
+}
+
+// parseList parses operands joined by op while more reports another
+// operand follows. Operands without search terms are dropped.
+func (p *queryParser) parseList(op string, operand func() (*queryNode, error), more func() bool) (*queryNode, error) {
+	node := &queryNode{op: op}
+	for {
+		child, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			node.children = append(node.children, child)
+		}
+		if !more() {
+			break
+		}
+	}
+	switch len(node.children) {
+	case 0:
+		return nil, nil
+	case 1:
+		return node.children[0], nil
+	}
+	return node, nil
+}
+
+// parseUnary parses a negation, a parenthesized query or a term
+func (p *queryParser) parseUnary() (*queryNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxSearchDepth {
+		return nil, &QueryError{Message: "query is nested too deeply"}
+	}
+
+	if p.pos >= len(p.tokens) {
+		return nil, &QueryError{Message: "query ends too early"}
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	switch token.kind {
+	case tokenNot:
+		child, err := p.parseUnary()
+		if err != nil || child == nil {
+			return nil, err
+		}
+		return &queryNode{op: opNot, children: []*queryNode{child}}, nil
+	case tokenOpen:
+		child, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != tokenClose {
+			return nil, &QueryError{Message: "missing )"}
+		}
+		p.pos++
+		return child, nil
+	case tokenWord, tokenPhrase:
+		// Skills are indexed under their canonical names
+		text := token.text
+		if token.field == "skill" {
+			text = textutil.CanonicalSkill(text)
+		}
+		var terms []string
+		for _, word := range tokenize(text) {
+			terms = append(terms, word.term)
+		}
+		if len(terms) == 0 {
+			return nil, nil
+		}
+		p.terms += len(terms)
+		if p.terms > maxSearchTerms {
+			return nil, &QueryError{Message: "query has too many terms"}
+		}
+		return &queryNode{op: opTerm, field: token.field, terms: terms}, nil
+	case tokenClose:
+		return nil, &QueryError{Message: "unexpected )"}
+	}
+	return nil, &QueryError{Message: "unexpected operator"}
+}
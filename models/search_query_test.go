@@ -0,0 +1,135 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// describe prints a query tree compactly, such as
+// (or (and go rust) (not skill:"machine learning"))
+func describe(node *queryNode) string {
+	switch node.op {
+	case opTerm:
+		term := strings.Join(node.terms, " ")
+		if len(node.terms) > 1 {
+			term = `"` + term + `"`
+		}
+		if node.field != "" {
+			term = node.field + ":" + term
+		}
+		return term
+	}
+	parts := []string{node.op}
+	for _, child := range node.children {
+		parts = append(parts, describe(child))
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		// Words next to each other must all match
+		{"go", "go"},
+		{"Go RUST", "(and go rust)"},
+		{"go AND rust", "(and go rust)"},
+
+		// NOT binds tightest, then AND, then OR
+		{"go OR rust java", "(or go (and rust java))"},
+		{"go AND rust OR java", "(or (and go rust) java)"},
+		{"NOT java go", "(and (not java) go)"},
+		{"NOT NOT java", "(not (not java))"},
+		{"(go OR rust) java", "(and (or go rust) java)"},
+		{"((go))", "go"},
+
+		// Operators only in capitals
+		{"go and rust or java", "(and go and rust or java)"},
+		{"not java", "(and not java)"},
+
+		// A leading - negates; elsewhere it splits words
+		{"go -intern", "(and go (not intern))"},
+		{"-(intern OR junior)", "(not (or intern junior))"},
+		{"front-end", `"front end"`},
+		{"go - rust", "(and go rust)"},
+
+		// Phrases and fields
+		{`"machine learning" python`, `(and "machine learning" python)`},
+		{"skill:python", "skill:python"},
+		{"Skills:python", "skill:python"},
+		{"text:python", "content:python"},
+		{`experience:"team lead"`, `experience:"team lead"`},
+		{"-education:phd", "(not education:phd)"},
+		{"skill:k8s", "skill:kubernetes"},
+		{"skill:ml", `skill:"machine learning"`},
+		{":go", "go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			root, err := parseSearchQuery(tt.query)
+			if err != nil {
+				t.Fatalf("parseSearchQuery(%q): %v", tt.query, err)
+			}
+			if got := describe(root); got != tt.want {
+				t.Errorf("parseSearchQuery(%q) = %s, want %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchQueryErrors(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("(", depth) + "go" + strings.Repeat(")", depth)
+	}
+	words := func(n int) string {
+		return strings.TrimSpace(strings.Repeat("go ", n))
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  string // Start of the message, empty when the query is valid
+	}{
+		{"empty", "", "query has no search terms"},
+		{"only symbols", "- ... !", "query has no search terms"},
+		{"only operators", "AND", "unexpected operator"},
+		{"unterminated quote", `go "machine learning`, "unterminated quote"},
+		{"unterminated field phrase", `skill:"machine`, "unterminated quote"},
+		{"unclosed (", "(go OR rust", "missing )"},
+		{"stray )", "go OR rust)", "unexpected )"},
+		{"leading )", ") go", "unexpected )"},
+		{"empty parentheses", "()", "unexpected )"},
+		{"dangling AND", "go AND", "query ends too early"},
+		{"dangling NOT", "go NOT", "query ends too early"},
+		{"unknown field", "salary:100k", "unknown field salary"},
+		{"too long", strings.Repeat("a", MaxSearchQueryLength+1), "query is too long"},
+
+		// Nesting and term limits
+		{"deepest nesting", nested(maxSearchDepth - 1), ""},
+		{"nested too deeply", nested(maxSearchDepth), "query is nested too deeply"},
+		{"negated too deeply", strings.Repeat("NOT ", maxSearchDepth) + "go", "query is nested too deeply"},
+		{"most terms", words(maxSearchTerms), ""},
+		{"too many terms", words(maxSearchTerms + 1), "query has too many terms"},
+		{"phrase words count", words(maxSearchTerms-1) + ` "machine learning"`, "query has too many terms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSearchQuery(tt.query)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("parseSearchQuery: %v", err)
+				}
+				return
+			}
+			var queryErr *QueryError
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("err = %v, want a QueryError", err)
+			}
+			if !strings.HasPrefix(queryErr.Message, tt.want) {
+				t.Errorf("err = %q, want %q", queryErr.Message, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"webscrapper/database"
+)
+
+// useSearchStore installs a memory store holding resumes; the search index
+// is built from it on the first search
+func useSearchStore(t *testing.T, resumes ...*database.Resume) {
+	t.Helper()
+	memory := database.NewMemoryStore()
+	SetStore(memory)
+	for _, resume := range resumes {
+		if resume.UploadedAt.IsZero() {
+			resume.UploadedAt = time.Now()
+		}
+		if err := memory.SaveResume(resume); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// searchIDs runs query over every resume and returns the IDs of the hits,
+// sorted
+func searchIDs(t *testing.T, query string) []string {
+	t.Helper()
+	results, err := SearchResumes("", "", query, 0, 100)
+	if err != nil {
+		t.Fatalf("SearchResumes(%q): %v", query, err)
+	}
+	ids := []string{}
+	for _, hit := range results.Hits {
+		ids = append(ids, hit.ResumeID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestSearchSkillAliases(t *testing.T) {
+	useSearchStore(t,
+		&database.Resume{ID: "canonical", UserID: "u1", Skills: []string{"go", "kubernetes", "node", "machine learning"}},
+		// Stored before skills were kept under their canonical names
+		&database.Resume{ID: "legacy", UserID: "u2", Skills: []string{"Golang", "k8s"}},
+		&database.Resume{ID: "other", UserID: "u3", Skills: []string{"java"}, Content: "golang k8s node.js"},
+	)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"skill:go", []string{"canonical", "legacy"}},
+		{"skill:golang", []string{"canonical", "legacy"}},
+		{"skill:k8s", []string{"canonical", "legacy"}},
+		{"skill:kubernetes", []string{"canonical", "legacy"}},
+		{"skill:node.js", []string{"canonical"}},
+		{"skill:ml", []string{"canonical"}},
+		{`skill:"machine learning"`, []string{"canonical"}},
+		{"skill:java", []string{"other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := searchIDs(t, tt.query); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchPhrases(t *testing.T) {
+	useSearchStore(t,
+		&database.Resume{ID: "lead", UserID: "u1", Experience: []string{"Senior engineer", "Team lead at Acme"}},
+		&database.Resume{ID: "split", UserID: "u2", Skills: []string{"machine", "learning"}, Content: "learning machine"},
+		&database.Resume{ID: "ml", UserID: "u3", Skills: []string{"machine learning"}},
+	)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`experience:"senior engineer"`, []string{"lead"}},
+		{`experience:"team lead"`, []string{"lead"}},
+		// Two values of a field are fieldGap apart, so no phrase spans them
+		{`experience:"engineer team"`, []string{}},
+		{`skill:"machine learning"`, []string{"ml"}},
+		// Word order matters
+		{`"machine learning"`, []string{"ml"}},
+		{`"learning machine"`, []string{"split"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := searchIDs(t, tt.query); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchNegation(t *testing.T) {
+	useSearchStore(t,
+		&database.Resume{ID: "go", UserID: "u1", Skills: []string{"go"}},
+		&database.Resume{ID: "java", UserID: "u2", Skills: []string{"java"}},
+		&database.Resume{ID: "intern", UserID: "u3", Skills: []string{"go"}, Experience: []string{"Intern"}},
+	)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// A query of only negations matches everything else
+		{"-java", []string{"go", "intern"}},
+		{"NOT skill:go", []string{"java"}},
+		{"-java -intern", []string{"go"}},
+		{"go -intern", []string{"go"}},
+		{"NOT (go OR java)", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := searchIDs(t, tt.query); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Negated terms are not scored
+	results, err := SearchResumes("", "", "-java", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hit := range results.Hits {
+		if hit.Score != 0 || len(hit.MatchedFields) != 0 {
+			t.Errorf("%s scored %v in %v", hit.ResumeID, hit.Score, hit.MatchedFields)
+		}
+	}
+}
+
+func TestSearchFieldWeights(t *testing.T) {
+	useSearchStore(t,
+		&database.Resume{ID: "content", UserID: "u1", Content: "Some rust on the side"},
+		&database.Resume{ID: "skill", UserID: "u2", Skills: []string{"rust"}},
+	)
+
+	results, err := SearchResumes("", "", "rust", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Hits) != 2 || results.Hits[0].ResumeID != "skill" {
+		t.Fatalf("hits = %+v, want the skill match first", results.Hits)
+	}
+	if fields := results.Hits[0].MatchedFields; len(fields) != 1 || fields[0] != "skill" {
+		t.Errorf("matched fields = %v, want [skill]", fields)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("filler ", 40)
+	tests := []struct {
+		name      string
+		text      string
+		highlight []string
+		want      string
+	}{
+		{"marks words", "Go and Rust", []string{"go", "rust"}, "<mark>Go</mark> and <mark>Rust</mark>"},
+		{"escapes text", `<script>alert("go")</script> & go`, []string{"go"},
+			"script&gt;alert(&#34;<mark>go</mark>&#34;)&lt;/script&gt; &amp; <mark>go</mark>"},
+		{"collapses space", "Go\n\n\tdeveloper", []string{"go"}, "<mark>Go</mark> developer"},
+		{"nothing to mark", "Go developer", nil, "Go developer"},
+		{"no words", "<>&", []string{"go"}, ""},
+		{"cuts around the match", long + "Go developer " + long, []string{"go"},
+			"… " + strings.TrimSpace(strings.Repeat("filler ", snippetLead)) + " <mark>Go</mark> developer " +
+				strings.TrimSpace(strings.Repeat("filler ", snippetLength-snippetLead-2)) + " …"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			highlight := make(map[string]bool)
+			for _, term := range tt.highlight {
+				highlight[term] = true
+			}
+			if got := snippet(tt.text, highlight); got != tt.want {
+				t.Errorf("snippet(%q) =\n%q\nwant\n%q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"errors"
+	"path/filepath"
+
+	"webscrapper/database"
+)
+
+// store is the backend every model function reads from and writes to.
+// It defaults to an in-memory store so the package works without setup.
+var store database.Store = database.NewMemoryStore()
+
+// SetStore replaces the storage backend used by the models layer
+func SetStore(s database.Store) {
+	store = s
+	searchIndex.reset()
+}
+
+// ErrSnapshotsUnsupported is returned when the store cannot be snapshotted
+var ErrSnapshotsUnsupported = errors.New("the configured store does not support snapshots")
+
+// resolveSnapshot returns the snapshot-capable store and the path of the
+// named snapshot file. An empty name means the configured snapshot file.
+func resolveSnapshot(name string) (database.Snapshotter, string, error) {
+	s, ok := store.(database.Snapshotter)
+	if !ok || s.SnapshotPath() == "" {
+		return nil, "", ErrSnapshotsUnsupported
+	}
+	if name == "" {
+		return s, s.SnapshotPath(), nil
+	}
+	// Only bare file names are accepted so callers cannot escape the directory
+	return s, filepath.Join(filepath.Dir(s.SnapshotPath()), filepath.Base(name)), nil
+}
+
+// SaveSnapshot writes the store contents to the named snapshot file
+func SaveSnapshot(name string) (string, error) {
+	s, path, err := resolveSnapshot(name)
+	if err != nil {
+		return "", err
+	}
+	return path, s.SaveSnapshot(path)
+}
+
+// RestoreSnapshot replaces the store contents with the named snapshot file
+func RestoreSnapshot(name string) (string, error) {
+	s, path, err := resolveSnapshot(name)
+	if err != nil {
+		return "", err
+	}
+	defer searchIndex.reset()
+	return path, s.LoadSnapshot(path)
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"webscrapper/database"
+)
+
+// fakeStore records the users the models layer saves and fails lookups on
+// demand. Methods it does not override panic through the nil embedded
+// Store, which flags calls a test did not expect.
+type fakeStore struct {
+	database.Store
+	users     map[string]*database.User
+	lookupErr error
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{users: make(map[string]*database.User)}
+}
+
+func (f *fakeStore) SaveUser(user *database.User) error {
+	for _, other := range f.users {
+		if other.Username == user.Username && other.ID != user.ID {
+			return &database.ConflictError{Field: "username"}
+		}
+	}
+	stored := *user
+	f.users[user.ID] = &stored
+	return nil
+}
+
+func (f *fakeStore) GetUserByID(id string) (*database.User, error) {
+	if f.lookupErr != nil {
+		return nil, f.lookupErr
+	}
+	user, exists := f.users[id]
+	if !exists {
+		return nil, nil
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (f *fakeStore) GetUserByUsername(username string) (*database.User, error) {
+	if f.lookupErr != nil {
+		return nil, f.lookupErr
+	}
+	for _, user := range f.users {
+		if user.Username == username {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+// useFakeStore installs a fake store for the length of the test
+func useFakeStore(t *testing.T) *fakeStore {
+	t.Helper()
+	fake := newFakeStore()
+	saved := store
+	SetStore(fake)
+	t.Cleanup(func() { SetStore(saved) })
+
+	// Keep password hashing fast
+	cost := passwordCost
+	if err := SetPasswordCost(bcrypt.MinCost); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { passwordCost = cost })
+	return fake
+}
+
+func TestCreateUserThroughStore(t *testing.T) {
+	fake := useFakeStore(t)
+
+	user, err := CreateUser("alice", "correct horse", " alice@example.com ")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	// The store received a hashed password and the default role
+	saved := fake.users[user.ID]
+	if saved == nil {
+		t.Fatal("user was not saved")
+	}
+	if saved.Password == "correct horse" || bcrypt.CompareHashAndPassword([]byte(saved.Password), []byte("correct horse")) != nil {
+		t.Errorf("stored password is not a bcrypt hash of the password")
+	}
+	if saved.Role != RoleCandidate || saved.Email != "alice@example.com" {
+		t.Errorf("saved user = %+v", saved)
+	}
+
+	// The returned user never carries the hash
+	if user.Password != "" {
+		t.Error("CreateUser returned the password hash")
+	}
+
+	// Conflicts from the store reach the caller unchanged
+	_, err = CreateUser("alice", "another password", "")
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Field != "username" {
+		t.Errorf("duplicate CreateUser err = %v, want username conflict", err)
+	}
+}
+
+func TestUserLookupsThroughStore(t *testing.T) {
+	fake := useFakeStore(t)
+	created, err := CreateUser("alice", "correct horse", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the login lookup exposes the hash, for VerifyPassword
+	byName, err := GetUserByUsername("alice")
+	if err != nil || byName == nil {
+		t.Fatalf("GetUserByUsername = %v, %v", byName, err)
+	}
+	if !VerifyPassword(byName, "correct horse") || VerifyPassword(byName, "wrong") {
+		t.Error("VerifyPassword does not check the stored hash")
+	}
+	byID, err := GetUserByID(created.ID)
+	if err != nil || byID == nil || byID.Password != "" {
+		t.Errorf("GetUserByID = %+v, %v", byID, err)
+	}
+
+	// Missing users are nil without an error
+	if user, err := GetUserByID("missing"); user != nil || err != nil {
+		t.Errorf("GetUserByID(missing) = %v, %v", user, err)
+	}
+
+	// Store errors are passed through
+	fake.lookupErr = errors.New("disk on fire")
+	if _, err := GetUserByUsername("alice"); !errors.Is(err, fake.lookupErr) {
+		t.Errorf("GetUserByUsername err = %v, want the store error", err)
+	}
+	if _, err := GetUserByID(created.ID); !errors.Is(err, fake.lookupErr) {
+		t.Errorf("GetUserByID err = %v, want the store error", err)
+	}
+}
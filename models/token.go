@@ -0,0 +1,127 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"webscrapper/database"
+)
+
+// RefreshTokenTTL is how long a refresh token stays valid
+var RefreshTokenTTL = 30 * 24 * time.Hour
+
+// Refresh token errors
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// IssueRefreshToken creates a refresh token for a user and returns the raw
+// token, which is never stored. An empty familyID starts a new family.
+func IssueRefreshToken(userID, familyID string) (token string, family string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(raw)
+
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+
+	err = store.SaveRefreshToken(&database.RefreshToken{
+		Hash:      hashRefreshToken(token),
+		UserID:    userID,
+		FamilyID:  familyID,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return token, familyID, nil
+}
+
+// RotateRefreshToken consumes a refresh token and issues its successor in
+// the same family. Presenting an already used token revokes the whole
+// family, since it means the token was stolen.
+func RotateRefreshToken(token string) (userID, familyID, next string, err error) {
+	stored, err := store.UseRefreshToken(hashRefreshToken(token))
+	if err != nil {
+		return "", "", "", err
+	}
+	if stored == nil || stored.Revoked || time.Now().After(stored.ExpiresAt) {
+		return "", "", "", ErrInvalidRefreshToken
+	}
+	if stored.Used {
+		log.Printf("Refresh token reuse for user %s, revoking family %s", stored.UserID, stored.FamilyID)
+		if err := store.RevokeRefreshTokenFamily(stored.FamilyID); err != nil {
+			return "", "", "", err
+		}
+		return "", "", "", ErrRefreshTokenReused
+	}
+
+	// Sessions from before a password reset or a membership change are over
+	dbUser, err := store.GetUserByID(stored.UserID)
+	if err != nil {
+		return "", "", "", err
+	}
+	if dbUser == nil || stored.CreatedAt.Before(dbUser.PasswordChangedAt) || stored.CreatedAt.Before(dbUser.SessionsRevokedAt) {
+		if err := store.RevokeRefreshTokenFamily(stored.FamilyID); err != nil {
+			return "", "", "", err
+		}
+		return "", "", "", ErrInvalidRefreshToken
+	}
+
+	next, _, err = IssueRefreshToken(stored.UserID, stored.FamilyID)
+	if err != nil {
+		return "", "", "", err
+	}
+	return stored.UserID, stored.FamilyID, next, nil
+}
+
+// RevokeTokenFamily revokes every refresh token issued in a login session
+func RevokeTokenFamily(familyID string) error {
+	return store.RevokeRefreshTokenFamily(familyID)
+}
+
+// RevokeAccessToken puts an access token ID on the denylist until it expires
+func RevokeAccessToken(jti string, expiresAt time.Time) error {
+	return store.RevokeAccessToken(jti, expiresAt)
+}
+
+// IsAccessTokenRevoked reports whether an access token has been revoked.
+// Store errors are treated as revoked so failures deny access.
+func IsAccessTokenRevoked(jti string) bool {
+	revoked, err := store.IsAccessTokenRevoked(jti)
+	if err != nil {
+		log.Println("Error checking token revocation:", err)
+		return true
+	}
+	return revoked
+}
+
+// IsSessionCurrent reports whether an access token issued to a user at
+// issuedAt is still good, which it stops being when an admin changes the
+// user's role or organization. Token times have whole seconds, so tokens
+// from the second of the change are rejected too. Store errors deny access.
+func IsSessionCurrent(userID string, issuedAt time.Time) bool {
+	dbUser, err := store.GetUserByID(userID)
+	if err != nil {
+		log.Println("Error checking session:", err)
+		return false
+	}
+	return dbUser != nil && issuedAt.After(dbUser.SessionsRevokedAt.Truncate(time.Second))
+}
+
+// hashRefreshToken returns the form of a refresh token kept in the store
+func hashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
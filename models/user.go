@@ -2,29 +2,103 @@ package models
 
 import (
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 	"webscrapper/database"
 )
 
+// MaxPasswordLength is the longest password bcrypt can hash, in bytes
+const MaxPasswordLength = 72
+
+// passwordCost is the bcrypt work factor for new hashes, see SetPasswordCost
+var passwordCost = bcrypt.DefaultCost
+
+// dummyHash is compared against when a login names an unknown user, so
+// the response time does not reveal whether the username exists
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// SetPasswordCost sets the bcrypt cost used for new and upgraded hashes.
+// Existing hashes with a lower cost are upgraded on the next successful login.
+func SetPasswordCost(cost int) error {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	passwordCost = cost
+	return nil
+}
+
 // User represents a user in the system
 type User struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"` // Password is never returned in JSON
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            string    `json:"id"`
+	Username      string    `json:"username"`
+	DisplayName   string    `json:"display_name,omitempty"`
+	Password      string    `json:"-"` // Password is never returned in JSON
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Role          string    `json:"role"`
+	OrgID         string    `json:"org_id,omitempty"`
+	MFAEnabled    bool      `json:"mfa_enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// User roles
+const (
+	RoleCandidate = "candidate" // Uploads and manages their own resumes
+	RoleRecruiter = "recruiter" // Views resumes shared with their organization
+	RoleAdmin     = "admin"     // Manages users in their organization
+)
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	return role == RoleCandidate || role == RoleRecruiter || role == RoleAdmin
 }
 
-// CreateUser creates a new user in the database
+// toUser converts a database user, leaving out the password hash
+func toUser(dbUser *database.User) *User {
+	role := dbUser.Role
+	if role == "" {
+		role = RoleCandidate
+	}
+	return &User{
+		ID:            dbUser.ID,
+		Username:      dbUser.Username,
+		DisplayName:   dbUser.DisplayName,
+		Email:         dbUser.Email,
+		EmailVerified: dbUser.EmailVerified,
+		Role:          role,
+		OrgID:         dbUser.OrgID,
+		MFAEnabled:    dbUser.TOTPEnabled,
+		CreatedAt:     dbUser.CreatedAt,
+	}
+}
+
+// ErrConflict is matched (via errors.Is) when a username or email is taken
+var ErrConflict = database.ErrConflict
+
+// ConflictError reports which unique field a write collided on
+type ConflictError = database.ConflictError
+
+// CreateUser creates a new user in the database.
+// It returns a *ConflictError if the username or email is already taken.
 func CreateUser(username, password, email string) (*User, error) {
+	email = strings.TrimSpace(email)
+
 	// Generate a unique ID
 	id := uuid.New().String()
 	
 	// Hash the password
-	hashedPassword := hashPassword(password)
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
 	
 	// Create user object
 	user := &database.User{
@@ -32,62 +106,177 @@ func CreateUser(username, password, email string) (*User, error) {
 		Username:  username,
 		Password:  hashedPassword,
 		Email:     email,
+		Role:      RoleCandidate,
 		CreatedAt: time.Now(),
 	}
 	
-	// Save to in-memory database
-	err := database.SaveUser(user)
+	// Save to the database
+	err = store.SaveUser(user)
 	if err != nil {
 		return nil, err
 	}
 	
-	return &User{
-		ID:        id,
-		Username:  username,
-		Email:     email,
-		CreatedAt: time.Now(),
-	}, nil
+	return toUser(user), nil
 }
 
 // GetUserByID retrieves a user by their ID
 func GetUserByID(id string) (*User, error) {
-	dbUser, err := database.GetUserByID(id)
+	dbUser, err := store.GetUserByID(id)
 	if err != nil || dbUser == nil {
 		return nil, err
 	}
 	
-	return &User{
-		ID:        dbUser.ID,
-		Username:  dbUser.Username,
-		Email:     dbUser.Email,
-		CreatedAt: dbUser.CreatedAt,
-	}, nil
+	return toUser(dbUser), nil
 }
 
-// GetUserByUsername retrieves a user by their username
+// GetUserByUsername retrieves a user by their username, including the
+// password hash for verification
 func GetUserByUsername(username string) (*User, error) {
-	dbUser, err := database.GetUserByUsername(username)
+	dbUser, err := store.GetUserByUsername(username)
 	if err != nil || dbUser == nil {
 		return nil, err
 	}
 	
-	return &User{
-		ID:        dbUser.ID,
-		Username:  dbUser.Username,
-		Password:  dbUser.Password,
-		Email:     dbUser.Email,
-		CreatedAt: dbUser.CreatedAt,
-	}, nil
+	user := toUser(dbUser)
+	user.Password = dbUser.Password
+	return user, nil
+}
+
+// GetUsersByOrgID retrieves every user in an organization
+func GetUsersByOrgID(orgID string) ([]*User, error) {
+	dbUsers, err := store.GetUsersByOrgID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	
+	var users []*User
+	for _, dbUser := range dbUsers {
+		users = append(users, toUser(dbUser))
+	}
+	return users, nil
+}
+
+// ErrInvalidRole is returned when assigning an unknown role
+var ErrInvalidRole = errors.New("invalid role")
+
+// UpdateUserRole changes a user's role, returning nil if the user does not exist
+func UpdateUserRole(id, role string) (*User, error) {
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	
+	dbUser, err := store.GetUserByID(id)
+	if err != nil || dbUser == nil {
+		return nil, err
+	}
+	if dbUser.Role != role {
+		dbUser.SessionsRevokedAt = time.Now()
+	}
+	dbUser.Role = role
+	if err := store.SaveUser(dbUser); err != nil {
+		return nil, err
+	}
+	return toUser(dbUser), nil
+}
+
+// AssignRole sets a user's role and organization by username.
+// It is used by the set-role command to bootstrap administrators.
+func AssignRole(username, role, orgID string) (*User, error) {
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	
+	dbUser, err := store.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if dbUser == nil {
+		return nil, fmt.Errorf("user %q not found", username)
+	}
+	if orgID != "" {
+		org, err := store.GetOrganizationByID(orgID)
+		if err != nil {
+			return nil, err
+		}
+		if org == nil {
+			return nil, ErrOrgNotFound
+		}
+	}
+	if err := moveUserToOrg(dbUser, orgID, role); err != nil {
+		return nil, err
+	}
+	return toUser(dbUser), nil
 }
 
-// VerifyPassword checks if the provided password matches the stored hash
+// VerifyPassword checks if the provided password matches the stored hash.
+// Legacy SHA-256 hashes and bcrypt hashes below the configured cost are
+// transparently replaced with a fresh hash after a successful check.
 func VerifyPassword(user *User, password string) bool {
-	hashedInput := hashPassword(password)
-	return user.Password == hashedInput
+	if user == nil {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+
+	if isLegacyHash(user.Password) {
+		hashedInput := legacyHashPassword(password)
+		if subtle.ConstantTimeCompare([]byte(user.Password), []byte(hashedInput)) != 1 {
+			return false
+		}
+		rehashPassword(user, password)
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return false
+	}
+	if cost, err := bcrypt.Cost([]byte(user.Password)); err == nil && cost < passwordCost {
+		rehashPassword(user, password)
+	}
+	return true
+}
+
+// rehashPassword stores a fresh hash of a verified password.
+// Failures are logged; the old hash keeps working until the next login.
+func rehashPassword(user *User, password string) {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		log.Printf("Failed to rehash password for user %s: %v", user.ID, err)
+		return
+	}
+
+	dbUser, err := store.GetUserByID(user.ID)
+	if err != nil || dbUser == nil {
+		log.Printf("Failed to load user %s for rehash: %v", user.ID, err)
+		return
+	}
+	dbUser.Password = hashedPassword
+	if err := store.SaveUser(dbUser); err != nil {
+		log.Printf("Failed to save rehashed password for user %s: %v", user.ID, err)
+		return
+	}
+	user.Password = hashedPassword
+}
+
+// hashPassword creates a salted bcrypt hash of the password
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// isLegacyHash reports whether a stored hash is an unsalted SHA-256 hex digest
+func isLegacyHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
 }
 
-// hashPassword creates a SHA-256 hash of the password
-func hashPassword(password string) string {
+// legacyHashPassword creates the SHA-256 hash used before bcrypt
+func legacyHashPassword(password string) string {
 	hash := sha256.Sum256([]byte(password))
 	return hex.EncodeToString(hash[:])
 }
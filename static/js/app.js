@@ -1,20 +1,37 @@
 // Global variables
 let currentPage = 'home';
 let token = localStorage.getItem('token');
+let refreshToken = localStorage.getItem('refreshToken');
 let user = JSON.parse(localStorage.getItem('user'));
 let currentResumeId = null;
 let resumes = [];
 let skillsChart = null;
 let activityChart = null;
+let localLoginEnabled = true;
 
 // DOM ready
-document.addEventListener('DOMContentLoaded', function() {
+document.addEventListener('DOMContentLoaded', async function() {
+    // Finish a single sign-on login before routing
+    if (window.location.hash.startsWith('#sso=')) {
+        await completeSSOLogin(window.location.hash.substring('#sso='.length));
+    }
+    
+    // Follow a link from a password reset or verification email
+    if (window.location.hash.startsWith('#reset=')) {
+        await completePasswordReset(window.location.hash.substring('#reset='.length));
+    } else if (window.location.hash.startsWith('#verify=')) {
+        await completeEmailVerification(window.location.hash.substring('#verify='.length));
+    }
+    
     // Initialize the app
     init();
     
     // Add event listeners
     setupEventListeners();
     
+    // Show the sign-in methods the server offers
+    loadAuthConfig();
+    
     // Handle routing
     handleRouting();
 });
@@ -70,6 +87,11 @@ function setupEventListeners() {
         navigateTo('login');
     });
     
+    document.getElementById('forgot-password').addEventListener('click', function(e) {
+        e.preventDefault();
+        handleForgotPassword();
+    });
+    
     // Back to dashboard button
     document.getElementById('back-to-dashboard').addEventListener('click', function() {
         navigateTo('dashboard');
@@ -79,6 +101,7 @@ function setupEventListeners() {
     document.getElementById('login-form').addEventListener('submit', handleLogin);
     document.getElementById('register-form').addEventListener('submit', handleRegister);
     document.getElementById('resume-upload-form').addEventListener('submit', handleResumeUpload);
+    document.getElementById('skill-gap-form').addEventListener('submit', handleSkillGap);
     
     // Logout
     document.getElementById('nav-logout').addEventListener('click', function(e) {
@@ -164,7 +187,7 @@ function updateNavForLoggedInUser() {
 // Update navigation for logged out user
 function updateNavForLoggedOutUser() {
     document.getElementById('nav-login').classList.remove('d-none');
-    document.getElementById('nav-register').classList.remove('d-none');
+    document.getElementById('nav-register').classList.toggle('d-none', !localLoginEnabled);
     document.getElementById('nav-logout').classList.add('d-none');
     document.getElementById('nav-dashboard').classList.add('d-none');
     document.getElementById('nav-upload').classList.add('d-none');
@@ -190,14 +213,18 @@ async function handleLogin(e) {
             throw new Error('Login failed');
         }
         
-        const data = await response.json();
+        let data = await response.json();
+        
+        // Complete the second factor step when required
+        if (data.mfa_required) {
+            data = await completeMFALogin(data);
+        }
         
         // Save token and user data
-        localStorage.setItem('token', data.token);
+        saveTokens(data);
         localStorage.setItem('user', JSON.stringify(data.user));
         
         // Update global variables
-        token = data.token;
         user = data.user;
         
         // Update UI
@@ -215,6 +242,37 @@ async function handleLogin(e) {
     }
 }
 
+// Finish a login that requires a second factor and return the tokens
+async function completeMFALogin(challenge) {
+    const post = async (url, body) => {
+        const response = await fetch(url, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify(body)
+        });
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        return response.json();
+    };
+    
+    // Enroll first if the organization requires it
+    if (challenge.enrollment_required) {
+        const enrollment = await post('/api/login/mfa/enroll', { mfa_token: challenge.mfa_token });
+        const code = prompt('Your organization requires two-factor authentication.\n' +
+            'Add this key to your authenticator app:\n\n' + enrollment.secret + '\n\nThen enter the 6-digit code:');
+        const data = await post('/api/login/mfa/enroll/confirm', { mfa_token: challenge.mfa_token, code });
+        alert('Save these recovery codes somewhere safe. Each works once if you lose your device:\n\n' +
+            data.recovery_codes.join('\n'));
+        return data;
+    }
+    
+    const code = prompt('Enter the 6-digit code from your authenticator app, or a recovery code:');
+    return post('/api/login/mfa', { mfa_token: challenge.mfa_token, code });
+}
+
 // Handle register form submission
 async function handleRegister(e) {
     e.preventDefault();
@@ -246,11 +304,10 @@ async function handleRegister(e) {
         const data = await response.json();
         
         // Save token and user data
-        localStorage.setItem('token', data.token);
+        saveTokens(data);
         localStorage.setItem('user', JSON.stringify(data.user));
         
         // Update global variables
-        token = data.token;
         user = data.user;
         
         // Update UI
@@ -268,14 +325,202 @@ async function handleRegister(e) {
     }
 }
 
+// Show or hide the local and SSO sign-in options
+async function loadAuthConfig() {
+    try {
+        const response = await fetch('/api/auth/config');
+        const config = await response.json();
+        localLoginEnabled = config.local_login;
+        
+        document.getElementById('sso-login').classList.toggle('d-none', !config.oidc);
+        document.getElementById('login-form').classList.toggle('d-none', !localLoginEnabled);
+        if (!localLoginEnabled) {
+            document.getElementById('nav-register').classList.add('d-none');
+        }
+    } catch (error) {
+        console.error('Auth config error:', error);
+    }
+}
+
+// Collect the tokens of a completed single sign-on login
+async function completeSSOLogin(code) {
+    try {
+        const response = await fetch('/api/oidc/token', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ code })
+        });
+        
+        if (!response.ok) {
+            throw new Error('SSO login failed');
+        }
+        
+        let data = await response.json();
+        
+        // Complete the second factor step when required
+        if (data.mfa_required) {
+            data = await completeMFALogin(data);
+        }
+        
+        // Save token and user data
+        saveTokens(data);
+        localStorage.setItem('user', JSON.stringify(data.user));
+        user = data.user;
+        
+        window.location.hash = 'dashboard';
+    } catch (error) {
+        alert('Single sign-on failed. Please try again.');
+        console.error('SSO login error:', error);
+        window.location.hash = 'login';
+    }
+}
+
+// Ask for a password reset email
+async function handleForgotPassword() {
+    const email = prompt('Enter the email address of your account:');
+    if (!email) {
+        return;
+    }
+    
+    try {
+        const response = await fetch('/api/password/forgot', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ email })
+        });
+        
+        if (!response.ok) {
+            throw new Error('Password reset request failed');
+        }
+        
+        alert('If an account uses that address, a reset link is on its way.');
+    } catch (error) {
+        alert('Could not request a password reset. Please try again.');
+        console.error('Forgot password error:', error);
+    }
+}
+
+// Choose a new password from a reset link
+async function completePasswordReset(resetToken) {
+    const password = prompt('Choose a new password:');
+    if (password) {
+        try {
+            const response = await fetch('/api/password/reset', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json'
+                },
+                body: JSON.stringify({ token: resetToken, password })
+            });
+            
+            if (!response.ok) {
+                throw new Error(await response.text());
+            }
+            
+            alert('Your password has been changed. Please log in.');
+        } catch (error) {
+            alert('This reset link is invalid or has expired.');
+            console.error('Password reset error:', error);
+        }
+    }
+    window.location.hash = 'login';
+}
+
+// Confirm an email address from a verification link
+async function completeEmailVerification(verifyToken) {
+    try {
+        const response = await fetch('/api/email/verify', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ token: verifyToken })
+        });
+        
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        
+        // Keep the cached profile in step
+        if (user) {
+            user.email_verified = true;
+            localStorage.setItem('user', JSON.stringify(user));
+        }
+        alert('Your email address is confirmed.');
+    } catch (error) {
+        alert('This verification link is invalid or has expired.');
+        console.error('Email verification error:', error);
+    }
+    window.location.hash = token && user ? 'dashboard' : 'login';
+}
+
+// Save an access/refresh token pair
+function saveTokens(data) {
+    localStorage.setItem('token', data.token);
+    localStorage.setItem('refreshToken', data.refresh_token);
+    token = data.token;
+    refreshToken = data.refresh_token;
+}
+
+// Exchange the refresh token for a new token pair
+async function refreshAccessToken() {
+    if (!refreshToken) {
+        return false;
+    }
+    
+    const response = await fetch('/api/token/refresh', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ refresh_token: refreshToken })
+    });
+    
+    if (!response.ok) {
+        return false;
+    }
+    
+    saveTokens(await response.json());
+    return true;
+}
+
+// Fetch an API route with the access token, refreshing it once if expired
+async function apiFetch(url, options = {}) {
+    const withAuth = () => ({
+        ...options,
+        headers: { ...(options.headers || {}), 'Authorization': `Bearer ${token}` }
+    });
+    
+    let response = await fetch(url, withAuth());
+    if (response.status === 401 && await refreshAccessToken()) {
+        response = await fetch(url, withAuth());
+    }
+    return response;
+}
+
 // Handle logout
-function handleLogout() {
+async function handleLogout() {
+    // Revoke the session on the server; local state is cleared regardless
+    if (token) {
+        try {
+            await apiFetch('/api/logout', { method: 'POST' });
+        } catch (error) {
+            console.error('Logout error:', error);
+        }
+    }
+    
     // Clear local storage
     localStorage.removeItem('token');
+    localStorage.removeItem('refreshToken');
     localStorage.removeItem('user');
     
     // Reset global variables
     token = null;
+    refreshToken = null;
     user = null;
     
     // Update UI
@@ -288,18 +533,26 @@ function handleLogout() {
 // Load dashboard data
 async function loadDashboardData() {
     try {
-        const response = await fetch('/api/resumes', {
-            headers: {
-                'Authorization': `Bearer ${token}`
+        // Page through the list, leaving out the extracted text
+        const fields = 'id,filename,title,tags,uploaded_at,skills,education,experience';
+        let loaded = [];
+        let cursor = '';
+        do {
+            const params = new URLSearchParams({ fields: fields, limit: '200' });
+            if (cursor) {
+                params.set('cursor', cursor);
             }
-        });
-        
-        if (!response.ok) {
-            throw new Error('Failed to load resumes');
-        }
-        
-        const data = await response.json();
-        resumes = data;
+            const response = await apiFetch('/api/resumes?' + params.toString());
+            
+            if (!response.ok) {
+                throw new Error('Failed to load resumes');
+            }
+            
+            const data = await response.json();
+            loaded = loaded.concat(data.resumes);
+            cursor = data.next_cursor;
+        } while (cursor);
+        resumes = loaded;
         
         // Update dashboard stats
         updateDashboardStats();
@@ -409,11 +662,19 @@ function renderResumes() {
 }
 
 // View resume details
-function viewResumeDetails(resumeId) {
+async function viewResumeDetails(resumeId) {
     currentResumeId = resumeId;
     
-    // Find the resume
-    const resume = resumes.find(r => r.id === resumeId);
+    // Load the full resume, the list leaves out its text
+    let resume;
+    try {
+        const response = await apiFetch(`/api/resumes/${encodeURIComponent(resumeId)}`);
+        if (response.ok) {
+            resume = await response.json();
+        }
+    } catch (error) {
+        console.error('Resume details error:', error);
+    }
     
     if (!resume) {
         alert('Resume not found');
@@ -481,10 +742,93 @@ function viewResumeDetails(resumeId) {
         experienceContainer.innerHTML = '<p>No experience details detected</p>';
     }
     
+    // Clear the skill gap report of the previous resume
+    document.getElementById('gap-results').innerHTML = '';
+    
     // Navigate to detail page
     navigateTo('resume-detail');
 }
 
+// Compare the current resume with a pasted job posting
+async function handleSkillGap(e) {
+    e.preventDefault();
+    
+    const jobText = document.getElementById('gap-job-text').value.trim();
+    if (!jobText || !currentResumeId) {
+        return;
+    }
+    
+    // Show loading spinner
+    document.getElementById('gap-spinner').style.display = 'inline-block';
+    document.getElementById('gap-button').style.display = 'none';
+    
+    try {
+        const response = await apiFetch(`/api/resumes/${encodeURIComponent(currentResumeId)}/gap`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ job_text: jobText })
+        });
+        
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        
+        renderSkillGap(await response.json());
+    } catch (error) {
+        alert('Comparison failed. Please try again.');
+        console.error('Skill gap error:', error);
+    } finally {
+        // Hide loading spinner
+        document.getElementById('gap-spinner').style.display = 'none';
+        document.getElementById('gap-button').style.display = 'inline-block';
+    }
+}
+
+// Render a skill gap report
+function renderSkillGap(report) {
+    const container = document.getElementById('gap-results');
+    container.innerHTML = '';
+    
+    // Adds a heading and a row of badges, or a note when there are none
+    const addSection = (title, items, badgeClass, emptyText) => {
+        const heading = document.createElement('h5');
+        heading.className = 'mt-3';
+        heading.textContent = title;
+        container.appendChild(heading);
+        
+        if (items.length === 0) {
+            const note = document.createElement('p');
+            note.className = 'text-muted';
+            note.textContent = emptyText;
+            container.appendChild(note);
+            return;
+        }
+        items.forEach(text => {
+            const badge = document.createElement('span');
+            badge.className = badgeClass;
+            badge.textContent = text;
+            container.appendChild(badge);
+        });
+    };
+    
+    // Keyword coverage
+    const coverage = document.createElement('p');
+    coverage.innerHTML = '<strong>Keyword coverage:</strong> ';
+    coverage.appendChild(document.createTextNode(`${report.keyword_coverage}% of the posting's main keywords`));
+    container.appendChild(coverage);
+    
+    addSection('Missing Skills', report.missing_skills.map(gap =>
+        `${gap.skill} (${gap.mentions} mention${gap.mentions === 1 ? '' : 's'})`
+    ), 'skill-badge bg-danger', 'This resume has every skill the posting names');
+    addSection('Present Skills', report.present_skills, 'skill-badge', 'None of the skills the posting names were found');
+    if (report.synonym_matches.length > 0) {
+        addSection('Matched Under Another Name', report.synonym_matches.map(match =>
+            `${match.skill}: "${match.job_term}" in posting, "${match.resume_term}" in resume`
+        ), 'skill-badge bg-secondary', '');
+    }
+    addSection('Missing Keywords', report.missing_keywords, 'skill-badge bg-warning text-dark', 'No keywords missing');
+}
+
 // Update charts
 function updateCharts() {
     // Skills chart
@@ -637,11 +981,8 @@ async function handleResumeUpload(e) {
     formData.append('resume', file);
     
     try {
-        const response = await fetch('/api/resumes/upload', {
+        const response = await apiFetch('/api/resumes/upload', {
             method: 'POST',
-            headers: {
-                'Authorization': `Bearer ${token}`
-            },
             body: formData
         });
         
@@ -0,0 +1,57 @@
+package textutil
+
+import "strings"
+
+// skillAliases maps skills to the other names they go by. Skills are
+// always reported under their own name.
+var skillAliases = map[string][]string{
+	"javascript":              {"js", "ecmascript"},
+	"go":                      {"golang"},
+	"c++":                     {"cpp"},
+	"c#":                      {"csharp", "c sharp"},
+	"html":                    {"html5"},
+	"css":                     {"css3"},
+	"node":                    {"node.js", "nodejs"},
+	"react":                   {"react.js", "reactjs"},
+	"angular":                 {"angular.js", "angularjs"},
+	"vue":                     {"vue.js", "vuejs"},
+	"express":                 {"express.js", "expressjs"},
+	"aws":                     {"amazon web services"},
+	"gcp":                     {"google cloud", "google cloud platform"},
+	"kubernetes":              {"k8s"},
+	"postgresql":              {"postgres"},
+	"mongodb":                 {"mongo"},
+	"elasticsearch":           {"elastic search"},
+	"rest api":                {"restful", "rest apis"},
+	"machine learning":        {"ml"},
+	"artificial intelligence": {"ai"},
+	"nlp":                     {"natural language processing"},
+	"scikit-learn":            {"sklearn"},
+}
+
+// aliasSkills maps each alias back to its skill
+var aliasSkills = func() map[string]string {
+	skills := make(map[string]string)
+	for skill, aliases := range skillAliases {
+		for _, alias := range aliases {
+			skills[alias] = skill
+		}
+	}
+	return skills
+}()
+
+// CanonicalSkill returns the taxonomy name of a skill given under any of
+// its names, lowercased
+func CanonicalSkill(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if skill, ok := aliasSkills[name]; ok {
+		return skill
+	}
+	return name
+}
+
+// SkillAliases returns the other names of a skill, given by its taxonomy
+// name
+func SkillAliases(skill string) []string {
+	return skillAliases[skill]
+}
@@ -0,0 +1,54 @@
+package textutil
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is a word of a text and its byte offsets
+type Token struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// Tokenize splits text into words of letters and digits, keeping their
+// case. A dot between two such characters stays inside the word (node.js,
+// b.tech), as do trailing + and # (c++, c#); any other character separates
+// words.
+func Tokenize(text string) []Token {
+	var tokens []Token
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isWordRune(r) {
+			i += size
+			continue
+		}
+		start := i
+		i += size
+		for i < len(text) {
+			r, size := utf8.DecodeRuneInString(text[i:])
+			if isWordRune(r) {
+				i += size
+				continue
+			}
+			if r == '.' && i+1 < len(text) {
+				if next, _ := utf8.DecodeRuneInString(text[i+1:]); isWordRune(next) {
+					i++
+					continue
+				}
+			}
+			break
+		}
+		for i < len(text) && (text[i] == '+' || text[i] == '#') {
+			i++
+		}
+		tokens = append(tokens, Token{Text: text[start:i], Start: start, End: i})
+	}
+	return tokens
+}
+
+// isWordRune reports whether r is a letter or digit
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,32 @@
+package textutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Go, Docker & k8s", []string{"Go", "Docker", "k8s"}},
+		{"Node.js and B.Tech.", []string{"Node.js", "and", "B.Tech"}},
+		{"C++/C# (expert)", []string{"C++", "C#", "expert"}},
+		{"end. Next", []string{"end", "Next"}},
+		{"naïve café", []string{"naïve", "café"}},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, token := range Tokenize(tt.text) {
+			if tt.text[token.Start:token.End] != token.Text {
+				t.Errorf("Tokenize(%q): token %q has offsets %d-%d", tt.text, token.Text, token.Start, token.End)
+			}
+			got = append(got, token.Text)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
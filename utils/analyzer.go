@@ -7,9 +7,10 @@ import (
 	"github.com/jdkato/prose/v2"
 )
 
-// Common skills that might be found in resumes
+// Common skills that might be found in resumes; see textutil.SkillAliases
+// for the other names they are found under
 var commonSkills = []string{
-	"javascript", "python", "java", "c++", "c#", "go", "golang", "ruby", "php", "swift",
+	"javascript", "python", "java", "c++", "c#", "go", "ruby", "php", "swift",
 	"html", "css", "react", "angular", "vue", "node", "express", "django", "flask", "spring",
 	"aws", "azure", "gcp", "docker", "kubernetes", "terraform", "jenkins", "git", "github",
 	"sql", "mysql", "postgresql", "mongodb", "redis", "elasticsearch", "graphql", "rest api",
@@ -26,6 +27,12 @@ var educationKeywords = []string{
 
 // AnalyzeResume extracts key information from resume text
 func AnalyzeResume(text string) (keywords []string, skills []string, education []string, experience []string) {
+	return AnalyzeResumeWithSkills(text, nil)
+}
+
+// AnalyzeResumeWithSkills is AnalyzeResume with additional skills to look
+// for, such as an organization's skill dictionary
+func AnalyzeResumeWithSkills(text string, extraSkills []string) (keywords []string, skills []string, education []string, experience []string) {
 	// Clean the text
 	text = cleanText(text)
 	
@@ -33,7 +40,7 @@ func AnalyzeResume(text string) (keywords []string, skills []string, education [
 	keywords = extractKeywords(text)
 	
 	// Extract skills
-	skills = extractSkills(text)
+	skills = extractSkills(text, extraSkills)
 	
 	// Extract education
 	education = extractEducation(text)
@@ -94,12 +101,19 @@ func extractKeywords(text string) []string {
 	return keywords
 }
 
-// extractSkills identifies skills mentioned in the resume
-func extractSkills(text string) []string {
+// extractSkills identifies skills mentioned in the resume as whole words,
+// under their own name or an alias
+func extractSkills(text string, extraSkills []string) []string {
 	var foundSkills []string
+	taxonomy := skillTaxonomy(extraSkills)
 	
-	for _, skill := range commonSkills {
-		if strings.Contains(text, skill) {
+	// Find the mentions, then list the skills in taxonomy order
+	found := make(map[string]bool)
+	for _, mention := range newSkillMatcher(taxonomy).find(text) {
+		found[mention.skill] = true
+	}
+	for _, skill := range taxonomy {
+		if found[skill] {
 			foundSkills = append(foundSkills, skill)
 		}
 	}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractSkills(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		extra []string
+		want  []string
+	}{
+		// Short names only match as whole words
+		{"go", "Go developer", nil, []string{"go"}},
+		{"good and google are not go", "Good engineer, worked at Google", nil, nil},
+		{"golang is go", "Five years of Golang", nil, []string{"go"}},
+		{"google cloud is gcp", "Deployed on Google Cloud", nil, []string{"gcp"}},
+		{"javascript is not java", "JavaScript and TypeScript", nil, []string{"javascript"}},
+		{"java and javascript", "Java backend, JavaScript frontend", nil, []string{"javascript", "java"}},
+		{"digital is not git", "Digital marketing specialist", nil, nil},
+		{"git", "Version control with git and GitHub", nil, []string{"git", "github"}},
+		{"r not in prose", "Our rollout reduced errors; we're proud of it", []string{"R"}, nil},
+		{"r not inside words", "Ran a team of researchers", []string{"R"}, nil},
+		{"r as a word", "Statistics in R and Python", []string{"R"}, []string{"python", "r"}},
+
+		// Aliases report the skill under its own name
+		{"node.js", "Built APIs with Node.js", nil, []string{"node"}},
+		{"node", "node and nodejs services", nil, []string{"node"}},
+		{"k8s", "Ran k8s clusters", nil, []string{"kubernetes"}},
+		{"postgres", "Postgres and MySQL", nil, []string{"mysql", "postgresql"}},
+		{"postgresql", "PostgreSQL tuning", nil, []string{"postgresql"}},
+
+		// Symbols stay part of the name
+		{"c++ and c#", "C++ and C# on Windows", nil, []string{"c++", "c#"}},
+		{"c as a word", "Wrote C code", []string{"c"}, []string{"c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractSkills(tt.text, tt.extra)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("extractSkills(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,48 +1,189 @@
 package utils
 
 import (
+	"crypto/subtle"
 	"errors"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
-// Secret key for signing JWT tokens
-// In production, this should be stored securely and not in source code
-var jwtSecret = []byte("your-secret-key-change-this-in-production")
+// errNoKeyring is returned when tokens are used before SetKeyring
+var errNoKeyring = errors.New("no JWT keyring configured")
+
+// AccessTokenTTL is how long an access token stays valid.
+// Clients renew it with a refresh token.
+var AccessTokenTTL = 15 * time.Minute
+
+// MFATokenTTL is how long a user has to enter their second factor after
+// the password step of a login
+var MFATokenTTL = 5 * time.Minute
+
+// mfaPurpose marks MFA challenge tokens, which are not access tokens
+const mfaPurpose = "mfa"
+
+// Purposes of the single-use tokens sent in emails
+const (
+	PurposePasswordReset = "password_reset"
+	PurposeVerifyEmail   = "verify_email"
+)
+
+// PasswordResetTTL is how long a password reset link stays valid
+var PasswordResetTTL = time.Hour
+
+// VerifyEmailTTL is how long an email verification link stays valid
+var VerifyEmailTTL = 48 * time.Hour
+
+// revocationCheck reports whether an access token ID has been revoked
+var revocationCheck = func(jti string) bool { return false }
+
+// SetRevocationCheck installs the denylist lookup used by AuthMiddleware
+func SetRevocationCheck(check func(jti string) bool) {
+	revocationCheck = check
+}
+
+// sessionCheck reports whether an access token issued to a user at
+// issuedAt is still good
+var sessionCheck = func(userID string, issuedAt time.Time) bool { return true }
+
+// SetSessionCheck installs the per-user token cutoff used by AuthMiddleware
+func SetSessionCheck(check func(userID string, issuedAt time.Time) bool) {
+	sessionCheck = check
+}
+
+// apiKeyAuthenticator resolves a raw API key to a principal, or nil if the
+// key is not valid
+var apiKeyAuthenticator = func(key string) (*Principal, error) { return nil, nil }
+
+// SetAPIKeyAuthenticator installs the API key lookup used by AuthMiddleware
+func SetAPIKeyAuthenticator(authenticate func(key string) (*Principal, error)) {
+	apiKeyAuthenticator = authenticate
+}
 
 // Claims represents the JWT claims
 type Claims struct {
-	UserID string `json:"user_id"`
+	UserID   string   `json:"user_id"`
+	Roles    []string `json:"roles,omitempty"`
+	OrgID    string   `json:"org_id,omitempty"`
+	FamilyID string   `json:"fid,omitempty"` // Refresh token family of the login session
+	Purpose  string   `json:"purpose,omitempty"` // Set on tokens that are not access tokens
+	Binding  string   `json:"bnd,omitempty"` // Account state a purpose token is only valid for
 	jwt.StandardClaims
 }
 
-// GenerateToken creates a new JWT token for a user
-func GenerateToken(userID string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // Token valid for 24 hours
+// GenerateToken creates a new short-lived access token for a principal.
+// UserID, Roles, OrgID and FamilyID are taken from the principal; the token
+// ID and expiry are assigned here.
+func GenerateToken(principal Principal) (string, error) {
+	expirationTime := time.Now().Add(AccessTokenTTL)
 	
 	claims := &Claims{
-		UserID: userID,
+		UserID:   principal.UserID,
+		Roles:    principal.Roles,
+		OrgID:    principal.OrgID,
+		FamilyID: principal.FamilyID,
 		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
 			ExpiresAt: expirationTime.Unix(),
 			IssuedAt:  time.Now().Unix(),
 		},
 	}
 	
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
+	if keyring == nil {
+		return "", errNoKeyring
+	}
+	key := keyring.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(key.signKey)
 	
 	return tokenString, err
 }
 
-// ValidateToken validates a JWT token and returns the claims
+// GenerateMFAToken creates a challenge token proving a user passed the
+// password step of a login. It only unlocks the second-factor step and is
+// rejected everywhere an access token is expected.
+func GenerateMFAToken(userID string) (string, error) {
+	return GeneratePurposeToken(mfaPurpose, userID, "", MFATokenTTL)
+}
+
+// ValidateMFAToken validates an MFA challenge token and returns its claims
+func ValidateMFAToken(tokenString string) (*Claims, error) {
+	return ValidatePurposeToken(tokenString, mfaPurpose)
+}
+
+// GeneratePurposeToken creates a token that is only good for one purpose,
+// such as an emailed password reset link. The binding is checked by the
+// caller against the account so the token dies once the account changes.
+func GeneratePurposeToken(purpose, userID, binding string, ttl time.Duration) (string, error) {
+	if keyring == nil {
+		return "", errNoKeyring
+	}
+	claims := &Claims{
+		UserID:  userID,
+		Purpose: purpose,
+		Binding: binding,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			ExpiresAt: time.Now().Add(ttl).Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
+	}
+	key := keyring.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+// ValidatePurposeToken validates a token created by GeneratePurposeToken
+// for the given purpose and returns its claims. Revoked tokens are rejected.
+func ValidatePurposeToken(tokenString, purpose string) (*Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose || claims.Id == "" || revocationCheck(claims.Id) {
+		return nil, errors.New("invalid " + purpose + " token")
+	}
+	return claims, nil
+}
+
+// ValidateToken validates an access token and returns the claims
 func ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("not an access token")
+	}
+	return claims, nil
+}
+
+// parseToken verifies a JWT and returns the claims.
+// The token must name a known key in its kid header and be signed with
+// exactly that key's algorithm.
+func parseToken(tokenString string) (*Claims, error) {
+	if keyring == nil {
+		return nil, errNoKeyring
+	}
 	claims := &Claims{}
 	
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
+	parser := jwt.NewParser(jwt.WithValidMethods(keyring.Methods()))
+	token, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keyring.Lookup(kid)
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.verifyKey, nil
 	})
 	
 	if err != nil {
@@ -72,7 +213,20 @@ func ExtractTokenFromRequest(r *http.Request) (string, error) {
 	return parts[1], nil
 }
 
-// AuthMiddleware is a middleware function to validate JWT tokens
+// ExtractAPIKeyFromRequest extracts an API key from the X-API-Key header or
+// an "Authorization: ApiKey {key}" header. It returns "" if there is none.
+func ExtractAPIKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	parts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(parts) == 2 && parts[0] == "ApiKey" {
+		return parts[1]
+	}
+	return ""
+}
+
+// AuthMiddleware is a middleware function to validate JWT tokens and API keys
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip authentication for login and register endpoints
@@ -81,6 +235,23 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 		
+		// Authenticate with an API key if one was sent
+		if key := ExtractAPIKeyFromRequest(r); key != "" {
+			principal, err := apiKeyAuthenticator(key)
+			if err != nil {
+				http.Error(w, "Failed to check API key", http.StatusInternalServerError)
+				return
+			}
+			if principal == nil {
+				http.Error(w, "Unauthorized: invalid API key", http.StatusUnauthorized)
+				return
+			}
+			r = r.WithContext(WithPrincipal(r.Context(), principal))
+			r.Header.Del("X-User-ID")
+			next.ServeHTTP(w, r)
+			return
+		}
+		
 		// Extract token from request
 		tokenString, err := ExtractTokenFromRequest(r)
 		if err != nil {
@@ -95,13 +266,111 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 		
-		// Add user ID to request context
-		ctx := r.Context()
-		r = r.WithContext(ctx)
+		// Reject revoked tokens
+		if claims.Id == "" || revocationCheck(claims.Id) {
+			http.Error(w, "Unauthorized: token has been revoked", http.StatusUnauthorized)
+			return
+		}
+		
+		// Reject tokens carrying a role or organization the user has lost
+		if !sessionCheck(claims.UserID, time.Unix(claims.IssuedAt, 0)) {
+			http.Error(w, "Unauthorized: token has been revoked", http.StatusUnauthorized)
+			return
+		}
+		
+		// Add the authenticated principal to the request context
+		principal := &Principal{
+			UserID:    claims.UserID,
+			Roles:     claims.Roles,
+			OrgID:     claims.OrgID,
+			TokenID:   claims.Id,
+			FamilyID:  claims.FamilyID,
+			ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+		}
+		r = r.WithContext(WithPrincipal(r.Context(), principal))
 		
-		// Set user ID in request header for handlers to access
-		r.Header.Set("X-User-ID", claims.UserID)
+		// Never trust a client-supplied identity header
+		r.Header.Del("X-User-ID")
 		
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequireRole only lets through principals holding at least one of the
+// given roles. It must run after AuthMiddleware.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := UserFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			for _, role := range roles {
+				if principal.HasRole(role) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		})
+	}
+}
+
+// RequireScope only lets through principals allowed to use scope.
+// Sessions always pass; API keys need the scope granted. It must run
+// after AuthMiddleware.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := UserFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if !principal.HasScope(scope) {
+				http.Error(w, "Forbidden: API key lacks the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession rejects API keys, for routes that only an interactive
+// login may use. It must run after AuthMiddleware.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := UserFromContext(r.Context())
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if principal.IsAPIKey() {
+			http.Error(w, "Forbidden: API keys cannot use this endpoint", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AdminTokenMiddleware guards operational endpoints with the shared secret
+// from the ADMIN_TOKEN environment variable, sent as X-Admin-Token.
+// The endpoints are disabled entirely when ADMIN_TOKEN is not set.
+func AdminTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminToken := os.Getenv("ADMIN_TOKEN")
+		if adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		provided := r.Header.Get("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(adminToken)) != 1 {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Principal is the authenticated identity attached to a request
+type Principal struct {
+	UserID    string
+	Roles     []string
+	OrgID     string
+	TokenID   string    // jti of the access token
+	FamilyID  string    // Refresh token family of the login session
+	ExpiresAt time.Time // Access token expiry
+
+	// Set when the request authenticated with an API key instead of a token
+	APIKeyID string
+	Scopes   []string
+}
+
+// HasRole reports whether the principal holds role
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAPIKey reports whether the principal authenticated with an API key
+func (p *Principal) IsAPIKey() bool {
+	return p.APIKeyID != ""
+}
+
+// HasScope reports whether the principal may use scope. Interactive
+// sessions are not restricted by scopes; API keys only hold those granted.
+func (p *Principal) HasScope(scope string) bool {
+	if !p.IsAPIKey() {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// contextKey is unexported so other packages cannot collide with our keys
+type contextKey int
+
+const principalKey contextKey = iota
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// UserFromContext returns the principal set by AuthMiddleware
+func UserFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// StripIdentityHeaders removes identity headers a client may have forged.
+// Identity is only ever carried in the request context.
+func StripIdentityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("X-User-ID")
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jdkato/prose/v2"
+	"webscrapper/textutil"
+)
+
+// maxGapKeywords is how many of a posting's most frequent keywords count
+// towards keyword coverage
+const maxGapKeywords = 30
+
+// SkillGapReport compares a resume with a job posting
+type SkillGapReport struct {
+	RequiredSkills  []string       `json:"required_skills"` // Most mentioned first
+	PresentSkills   []string       `json:"present_skills"`
+	MissingSkills   []MissingSkill `json:"missing_skills"` // Most mentioned first
+	SynonymMatches  []SynonymMatch `json:"synonym_matches"`
+	KeywordCoverage int            `json:"keyword_coverage"` // Percent of posting keywords in the resume
+	MatchedKeywords []string       `json:"matched_keywords"`
+	MissingKeywords []string       `json:"missing_keywords"`
+}
+
+// MissingSkill is a skill the posting asks for that the resume lacks
+type MissingSkill struct {
+	Skill    string `json:"skill"`
+	Mentions int    `json:"mentions"`
+}
+
+// SynonymMatch is a skill the posting and the resume name differently
+type SynonymMatch struct {
+	Skill      string `json:"skill"`
+	JobTerm    string `json:"job_term"`
+	ResumeTerm string `json:"resume_term"`
+}
+
+// postingSkill is a taxonomy skill found in a posting
+type postingSkill struct {
+	skill    string
+	term     string
+	mentions int
+}
+
+// SkillGap reports which skills of the taxonomy, plus extraSkills, a job
+// posting asks for and whether the resume has them, under any of their
+// names. resumeSkills are the skills the analyzer found in the resume.
+func SkillGap(jobText, resumeText string, resumeSkills, extraSkills []string) SkillGapReport {
+	resumeText = cleanText(resumeText)
+	report := SkillGapReport{
+		RequiredSkills:  []string{},
+		PresentSkills:   []string{},
+		MissingSkills:   []MissingSkill{},
+		SynonymMatches:  []SynonymMatch{},
+		MatchedKeywords: []string{},
+		MissingKeywords: []string{},
+	}
+
+	// Skills the posting names, most mentioned first
+	taxonomy := skillTaxonomy(extraSkills)
+	matcher := newSkillMatcher(taxonomy)
+	inJob := countSkills(matcher.find(jobText))
+	inResume := countSkills(matcher.find(resumeText))
+	var wanted []postingSkill
+	for _, skill := range taxonomy {
+		if count := inJob[skill]; count.mentions > 0 {
+			wanted = append(wanted, postingSkill{skill: skill, term: count.term, mentions: count.mentions})
+		}
+	}
+	sort.SliceStable(wanted, func(i, j int) bool {
+		return wanted[i].mentions > wanted[j].mentions
+	})
+
+	// Check the resume for each of them. Stored skills may come from before
+	// matching by whole words, so they only count for resumes without text.
+	analyzed := make(map[string]bool)
+	if resumeText == "" {
+		for _, skill := range resumeSkills {
+			analyzed[textutil.CanonicalSkill(skill)] = true
+		}
+	}
+	for _, want := range wanted {
+		report.RequiredSkills = append(report.RequiredSkills, want.skill)
+		found := inResume[want.skill]
+		if found.mentions == 0 && analyzed[want.skill] {
+			found = skillCount{mentions: 1, term: want.skill}
+		}
+		if found.mentions == 0 {
+			report.MissingSkills = append(report.MissingSkills, MissingSkill{Skill: want.skill, Mentions: want.mentions})
+			continue
+		}
+		report.PresentSkills = append(report.PresentSkills, want.skill)
+		if found.term != want.term {
+			report.SynonymMatches = append(report.SynonymMatches, SynonymMatch{Skill: want.skill, JobTerm: want.term, ResumeTerm: found.term})
+		}
+	}
+
+	// Keyword coverage, leaving out the skills reported above. Keywords are
+	// long enough to match anywhere, which also finds them in PDF text that
+	// lost its spaces.
+	skillNames := make(map[string]bool)
+	for _, skill := range taxonomy {
+		skillNames[skill] = true
+		for _, name := range textutil.SkillAliases(skill) {
+			skillNames[name] = true
+		}
+	}
+	keywords := postingKeywords(jobText, skillNames)
+	for _, keyword := range keywords {
+		if strings.Contains(resumeText, keyword) {
+			report.MatchedKeywords = append(report.MatchedKeywords, keyword)
+		} else {
+			report.MissingKeywords = append(report.MissingKeywords, keyword)
+		}
+	}
+	if len(keywords) > 0 {
+		report.KeywordCoverage = (100*len(report.MatchedKeywords) + len(keywords)/2) / len(keywords)
+	}
+	return report
+}
+
+// postingKeywords returns the nouns a posting uses most, most frequent
+// first, other than those in exclude
+func postingKeywords(text string, exclude map[string]bool) []string {
+	doc, err := prose.NewDocument(text, prose.WithExtraction(false))
+	if err != nil {
+		return nil
+	}
+	counts := make(map[string]int)
+	for _, tok := range doc.Tokens() {
+		keyword := strings.ToLower(tok.Text)
+		if strings.HasPrefix(tok.Tag, "NN") && len(keyword) > 3 && !exclude[keyword] {
+			counts[keyword]++
+		}
+	}
+
+	keywords := make([]string, 0, len(counts))
+	for keyword := range counts {
+		keywords = append(keywords, keyword)
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if counts[keywords[i]] != counts[keywords[j]] {
+			return counts[keywords[i]] > counts[keywords[j]]
+		}
+		return keywords[i] < keywords[j]
+	})
+	if len(keywords) > maxGapKeywords {
+		keywords = keywords[:maxGapKeywords]
+	}
+	return keywords
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSkillGapRanking(t *testing.T) {
+	job := "Kubernetes experience. You will run kubernetes and k8s tooling. Go services. Docker and docker compose."
+	report := SkillGap(job, "Go and Docker", nil, nil)
+
+	// Most mentioned first, counting every name of a skill
+	if got := strings.Join(report.RequiredSkills, ","); got != "kubernetes,docker,go" {
+		t.Errorf("required = %s, want kubernetes,docker,go", got)
+	}
+	if got := strings.Join(report.PresentSkills, ","); got != "docker,go" {
+		t.Errorf("present = %s, want docker,go", got)
+	}
+	if len(report.MissingSkills) != 1 || report.MissingSkills[0] != (MissingSkill{Skill: "kubernetes", Mentions: 3}) {
+		t.Errorf("missing = %+v, want kubernetes with 3 mentions", report.MissingSkills)
+	}
+	if len(report.SynonymMatches) != 0 {
+		t.Errorf("synonyms = %+v, want none", report.SynonymMatches)
+	}
+}
+
+func TestSkillGapSynonyms(t *testing.T) {
+	tests := []struct {
+		name   string
+		job    string
+		resume string
+		want   string // Skill, job term and resume term; empty for none
+	}{
+		{"alias in job", "Golang developer", "Go services", "go golang go"},
+		{"alias in resume", "Go developer", "Golang services", "go go golang"},
+		{"two aliases", "Postgres and k8s", "PostgreSQL on Kubernetes", "kubernetes k8s kubernetes,postgresql postgres postgresql"},
+		{"most used name", "Node.js, Node.js and nodejs", "node and node", "node node.js node"},
+		{"same name", "Kubernetes", "kubernetes", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, match := range SkillGap(tt.job, tt.resume, nil, nil).SynonymMatches {
+				got = append(got, fmt.Sprintf("%s %s %s", match.Skill, match.JobTerm, match.ResumeTerm))
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("synonyms = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkillGapSources(t *testing.T) {
+	// Extra skills join the taxonomy
+	report := SkillGap("Pulumi and Go", "Go", nil, []string{"Pulumi"})
+	if len(report.MissingSkills) != 1 || report.MissingSkills[0].Skill != "pulumi" {
+		t.Errorf("missing = %+v, want pulumi", report.MissingSkills)
+	}
+
+	// Stored skills only stand in for a missing resume text
+	if report := SkillGap("Go and Docker", "", []string{"golang"}, nil); strings.Join(report.PresentSkills, ",") != "go" {
+		t.Errorf("without text: present = %v, want [go]", report.PresentSkills)
+	}
+	if report := SkillGap("Go and Docker", "Java", []string{"golang"}, nil); len(report.PresentSkills) != 0 {
+		t.Errorf("with text: present = %v, want none", report.PresentSkills)
+	}
+
+	// Whole words only
+	if report := SkillGap("Java backend", "JavaScript frontend", nil, nil); len(report.PresentSkills) != 0 {
+		t.Errorf("javascript resume: present = %v, want none", report.PresentSkills)
+	}
+}
+
+func TestSkillGapKeywords(t *testing.T) {
+	job := "Kubernetes for payments and invoices. Payments at scale."
+	report := SkillGap(job, "Built the payments team", nil, nil)
+
+	// Skills are left out of the keywords, and the most used come first
+	if got := strings.Join(report.MatchedKeywords, ","); got != "payments" {
+		t.Errorf("matched = %s, want payments", got)
+	}
+	if got := strings.Join(report.MissingKeywords, ","); got != "invoices,scale" {
+		t.Errorf("missing = %s, want invoices,scale", got)
+	}
+	if report.KeywordCoverage != 33 {
+		t.Errorf("coverage = %d, want 33", report.KeywordCoverage)
+	}
+	if report := SkillGap("Go", "Go", nil, nil); report.KeywordCoverage != 0 || len(report.MissingKeywords) != 0 {
+		t.Errorf("no keywords: %+v", report)
+	}
+}
@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// minSecretLength is the shortest HS256 secret we accept, in bytes
+const minSecretLength = 32
+
+// SigningKey is one JWT key identified by its kid header.
+// Verify-only keys (retired keys kept for rotation) have no private half.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// CanSign reports whether the key holds private material
+func (k *SigningKey) CanSign() bool {
+	return k.signKey != nil
+}
+
+// Keyring holds the active signing key plus retired keys that are still
+// accepted for verification, so tokens survive a key rotation
+type Keyring struct {
+	mutex  sync.RWMutex
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewKeyring creates a keyring that signs with active and also verifies
+// tokens signed by any of the retired keys
+func NewKeyring(active *SigningKey, retired ...*SigningKey) (*Keyring, error) {
+	if active == nil || !active.CanSign() {
+		return nil, errors.New("active JWT key must include private key material")
+	}
+	k := &Keyring{active: active, keys: map[string]*SigningKey{active.ID: active}}
+	for _, key := range retired {
+		if _, exists := k.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate JWT key id %q", key.ID)
+		}
+		k.keys[key.ID] = key
+	}
+	return k, nil
+}
+
+// Active returns the key new tokens are signed with
+func (k *Keyring) Active() *SigningKey {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.active
+}
+
+// Lookup returns the key with the given kid
+func (k *Keyring) Lookup(kid string) (*SigningKey, bool) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// Methods returns the algorithms of every key in the ring
+func (k *Keyring) Methods() []string {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	seen := make(map[string]bool)
+	var methods []string
+	for _, key := range k.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// keyring is the process-wide keyring used by GenerateToken and ValidateToken
+var keyring *Keyring
+
+// SetKeyring replaces the keyring used to sign and validate tokens
+func SetKeyring(k *Keyring) {
+	keyring = k
+}
+
+// NewHMACKey creates an HS256 key from a shared secret
+func NewHMACKey(kid string, secret []byte) (*SigningKey, error) {
+	if len(secret) < minSecretLength {
+		return nil, fmt.Errorf("HS256 secret must be at least %d bytes", minSecretLength)
+	}
+	if kid == "" {
+		kid = fingerprint(secret)
+	}
+	return &SigningKey{ID: kid, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}, nil
+}
+
+// NewAsymmetricKey creates an RS256 or EdDSA key from PEM data.
+// The PEM may hold a private key (sign and verify) or a public key (verify only).
+func NewAsymmetricKey(kid, alg string, pemData []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var signKey crypto.Signer
+	var verifyKey crypto.PublicKey
+	if parsed, err := parsePrivateKey(block.Bytes); err == nil {
+		signKey = parsed
+		verifyKey = parsed.Public()
+	} else if parsed, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		verifyKey = parsed
+	} else {
+		return nil, errors.New("PEM block is neither a private nor a public key")
+	}
+
+	key := &SigningKey{ID: kid, verifyKey: verifyKey}
+	switch alg {
+	case "RS256":
+		if _, ok := verifyKey.(*rsa.PublicKey); !ok {
+			return nil, errors.New("RS256 requires an RSA key")
+		}
+		key.Method = jwt.SigningMethodRS256
+	case "EdDSA":
+		if _, ok := verifyKey.(ed25519.PublicKey); !ok {
+			return nil, errors.New("EdDSA requires an Ed25519 key")
+		}
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+	}
+	if signKey != nil {
+		key.signKey = signKey
+	}
+
+	if key.ID == "" {
+		der, err := x509.MarshalPKIXPublicKey(verifyKey)
+		if err != nil {
+			return nil, err
+		}
+		key.ID = fingerprint(der)
+	}
+	return key, nil
+}
+
+// parsePrivateKey accepts PKCS#8 and PKCS#1 encoded private keys
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, errors.New("unsupported private key type")
+	}
+	return x509.ParsePKCS1PrivateKey(der)
+}
+
+// fingerprint derives a stable key ID from key material
+func fingerprint(material []byte) string {
+	sum := sha256.Sum256(material)
+	return hex.EncodeToString(sum[:8])
+}
+
+// loadKey builds a key of the given algorithm from a secret or PEM file
+func loadKey(kid, alg string, data []byte) (*SigningKey, error) {
+	if alg == "HS256" {
+		return NewHMACKey(kid, []byte(strings.TrimSpace(string(data))))
+	}
+	return NewAsymmetricKey(kid, alg, data)
+}
+
+// LoadKeyringFromEnv builds the keyring from environment variables:
+//
+//	JWT_ALG               HS256 (default), RS256 or EdDSA
+//	JWT_KID               key ID of the active key (default: key fingerprint)
+//	JWT_SECRET            HS256 secret, or JWT_SECRET_FILE to read it from a file
+//	JWT_PRIVATE_KEY_FILE  PEM private key for RS256 and EdDSA
+//	JWT_RETIRED_KEYS      comma-separated kid=alg:path entries still accepted
+//	                      for verification (secret file or PEM key)
+//
+// Without any key configured a random HS256 secret is generated, which
+// means tokens do not survive a restart.
+func LoadKeyringFromEnv() (*Keyring, error) {
+	alg := os.Getenv("JWT_ALG")
+	if alg == "" {
+		alg = "HS256"
+	}
+	kid := os.Getenv("JWT_KID")
+
+	// Active key
+	var data []byte
+	var err error
+	switch {
+	case alg == "HS256" && os.Getenv("JWT_SECRET") != "":
+		data = []byte(os.Getenv("JWT_SECRET"))
+	case alg == "HS256" && os.Getenv("JWT_SECRET_FILE") != "":
+		data, err = os.ReadFile(os.Getenv("JWT_SECRET_FILE"))
+	case alg == "HS256":
+		log.Println("WARNING: no JWT_SECRET configured, using a random secret; tokens will not survive a restart")
+		// Hex keeps the secret intact through the whitespace trimming in loadKey
+		random := make([]byte, minSecretLength)
+		_, err = rand.Read(random)
+		data = []byte(hex.EncodeToString(random))
+	case os.Getenv("JWT_PRIVATE_KEY_FILE") != "":
+		data, err = os.ReadFile(os.Getenv("JWT_PRIVATE_KEY_FILE"))
+	default:
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	active, err := loadKey(kid, alg, data)
+	if err != nil {
+		return nil, fmt.Errorf("active JWT key: %w", err)
+	}
+
+	// Retired keys still accepted for verification
+	var retired []*SigningKey
+	for _, entry := range strings.Split(os.Getenv("JWT_RETIRED_KEYS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, rest, ok1 := strings.Cut(entry, "=")
+		keyAlg, path, ok2 := strings.Cut(rest, ":")
+		if !ok1 || !ok2 || id == "" {
+			return nil, fmt.Errorf("invalid JWT_RETIRED_KEYS entry %q, want kid=alg:path", entry)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := loadKey(id, keyAlg, data)
+		if err != nil {
+			return nil, fmt.Errorf("retired JWT key %q: %w", id, err)
+		}
+		// Retired keys never sign, even when given private material
+		key.signKey = nil
+		retired = append(retired, key)
+	}
+
+	return NewKeyring(active, retired...)
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// Mailer sends email
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// errHeaderInjection is returned for addresses or subjects with line breaks
+var errHeaderInjection = errors.New("email header contains a line break")
+
+// SMTPMailer sends email through an SMTP server. STARTTLS is used when the
+// server offers it.
+type SMTPMailer struct {
+	Addr     string // host:port
+	From     string
+	Username string // Empty to send without authentication
+	Password string
+}
+
+// Send delivers a message to the SMTP server
+func (m *SMTPMailer) Send(msg Message) error {
+	if strings.ContainsAny(msg.To+msg.Subject+m.From, "\r\n") {
+		return errHeaderInjection
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", m.From)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, []byte(body.String()))
+}
+
+// MemoryMailer keeps sent messages in memory instead of delivering them
+type MemoryMailer struct {
+	mutex    sync.Mutex
+	messages []Message
+}
+
+// Send records a message
+func (m *MemoryMailer) Send(msg Message) error {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return errHeaderInjection
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	msg.SentAt = time.Now()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far
+func (m *MemoryMailer) Messages() []Message {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// FileMailer appends each message to a file as a line of JSON, which is
+// handy for development and end-to-end tests
+type FileMailer struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+// Send appends a message to the file
+func (m *FileMailer) Send(msg Message) error {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return errHeaderInjection
+	}
+	msg.SentAt = time.Now()
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadMailerFromEnv configures outgoing email from these variables:
+//
+//	SMTP_HOST      SMTP server; enables SMTP delivery
+//	SMTP_PORT      SMTP port, 587 by default
+//	SMTP_USERNAME  SMTP login, if the server requires one
+//	SMTP_PASSWORD  SMTP password
+//	MAIL_FROM      Sender address
+//	MAIL_FILE      Append messages to this file instead of sending them
+//
+// Without either SMTP_HOST or MAIL_FILE messages are kept in memory and
+// never delivered.
+func LoadMailerFromEnv() (Mailer, error) {
+	if path := os.Getenv("MAIL_FILE"); path != "" {
+		return &FileMailer{Path: path}, nil
+	}
+
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Println("Warning: SMTP_HOST is not set, emails will not be delivered")
+		return &MemoryMailer{}, nil
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		return nil, errors.New("MAIL_FROM is required with SMTP_HOST")
+	}
+	return &SMTPMailer{
+		Addr:     net.JoinHostPort(host, port),
+		From:     from,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}, nil
+}
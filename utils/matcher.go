@@ -0,0 +1,421 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"webscrapper/textutil"
+)
+
+// Evidence limits per score component
+const (
+	maxEvidence         = 3
+	maxEvidenceLength   = 200
+	minSentenceLength   = 3
+	evidenceLeadingText = 60 // Text kept before the match in long sentences
+)
+
+// Score components of a match
+const (
+	ComponentRequiredSkills   = "required_skills"
+	ComponentNiceToHaveSkills = "nice_to_have_skills"
+	ComponentExperience       = "experience"
+	ComponentEducation        = "education"
+	ComponentKeywords         = "keywords"
+)
+
+// Rubric weighs the components of a match score. Components a job does not
+// ask for, or weighted 0, are left out and the others scaled up to 100.
+type Rubric struct {
+	RequiredSkills   float64 `json:"required_skills"`
+	NiceToHaveSkills float64 `json:"nice_to_have_skills"`
+	Experience       float64 `json:"experience"`
+	Education        float64 `json:"education"`
+	Keywords         float64 `json:"keywords"`
+}
+
+// DefaultRubric weighs matches for jobs without a rubric of their own
+var DefaultRubric = Rubric{
+	RequiredSkills:   40,
+	NiceToHaveSkills: 20,
+	Experience:       15,
+	Education:        10,
+	Keywords:         15,
+}
+
+// JobRequirements is what a resume is scored against
+type JobRequirements struct {
+	RequiredSkills   []string
+	NiceToHaveSkills []string
+	MinYears         int
+	MinEducation     string   // One of EducationLevels, or empty
+	Keywords         []string // Keywords of the job description
+	Rubric           *Rubric  // DefaultRubric when nil
+}
+
+// MatchResult is how well a resume fits a job
+type MatchResult struct {
+	Score                 int              `json:"score"` // 0 to 100, the sum of the breakdown's points
+	MatchedSkills         []string         `json:"matched_skills"`
+	MissingRequiredSkills []string         `json:"missing_required_skills"`
+	YearsOfExperience     int              `json:"years_of_experience"`
+	EducationLevel        string           `json:"education_level,omitempty"`
+	Breakdown             []ScoreComponent `json:"breakdown"`
+}
+
+// ScoreComponent explains one part of a match score
+type ScoreComponent struct {
+	Component string   `json:"component"`
+	Weight    float64  `json:"weight"`
+	Match     float64  `json:"match"`  // Share of the component met, 0 to 1
+	Points    float64  `json:"points"` // Points earned towards the score
+	Detail    string   `json:"detail"`
+	Evidence  []string `json:"evidence"` // Resume sentences that earned the points
+}
+
+// EducationLevels lists education levels from lowest to highest
+var EducationLevels = []string{"associate", "bachelor", "master", "phd"}
+
+// educationTerms are the phrases that name each education level
+var educationTerms = map[string][]string{
+	"associate": {"associate degree", "associate's", "associate of", "diploma"},
+	"bachelor":  {"bachelor", "b.tech", "b.sc", "bsc", "b.e.", "b.s.", "b.a.", "undergraduate"},
+	"master":    {"master's", "masters", "master of", "m.tech", "m.sc", "msc", "m.e.", "m.s.", "mba"},
+	"phd":       {"phd", "ph.d", "doctorate", "doctoral"},
+}
+
+// Patterns used to estimate years of experience and split resume text
+var (
+	yearsStatedPattern = regexp.MustCompile(`(?i)\b(\d{1,2})\+?\s*(?:years|yrs)\b`)
+	yearRangePattern   = regexp.MustCompile(`(?i)(?:^|\D)((?:19|20)\d{2})\s*[-–—]\s*((?:19|20)\d{2}|present|current|now)`) // PDF text often lacks spaces
+	sentenceBreak      = regexp.MustCompile(`[\r\n]+|[.!?;](?:\s+|$)|\s*[•●▪]\s*`)
+	whitespaceRun      = regexp.MustCompile(`\s+`)
+)
+
+// MatchResume scores a resume's skills and text against a job's
+// requirements, weighing each component by the job's rubric
+func MatchResume(job JobRequirements, skills []string, text string) MatchResult {
+	rubric := DefaultRubric
+	if job.Rubric != nil {
+		rubric = *job.Rubric
+	}
+	result := MatchResult{
+		MatchedSkills:         []string{},
+		MissingRequiredSkills: []string{},
+		YearsOfExperience:     EstimateYearsOfExperience(text),
+		Breakdown:             []ScoreComponent{},
+	}
+	clean := cleanText(text)
+	sentences := splitSentences(text)
+
+	// Skills the text names. Stored skills may come from before matching
+	// by whole words, so they only count for resumes without text.
+	have := make(map[string]bool)
+	if strings.TrimSpace(text) == "" {
+		for _, skill := range skills {
+			have[textutil.CanonicalSkill(skill)] = true
+		}
+	}
+	named := countSkills(newSkillMatcher(append(append([]string{}, job.RequiredSkills...), job.NiceToHaveSkills...)).find(text))
+	hasSkill := func(skill string) bool {
+		skill = textutil.CanonicalSkill(skill)
+		return have[skill] || named[skill].mentions > 0
+	}
+
+	if len(job.RequiredSkills) > 0 {
+		var matched []string
+		for _, skill := range job.RequiredSkills {
+			if hasSkill(skill) {
+				matched = append(matched, skill)
+			} else {
+				result.MissingRequiredSkills = append(result.MissingRequiredSkills, skill)
+			}
+		}
+		result.MatchedSkills = append(result.MatchedSkills, matched...)
+		result.Breakdown = append(result.Breakdown, ScoreComponent{
+			Component: ComponentRequiredSkills,
+			Weight:    rubric.RequiredSkills,
+			Match:     float64(len(matched)) / float64(len(job.RequiredSkills)),
+			Detail:    fmt.Sprintf("%d of %d required skills", len(matched), len(job.RequiredSkills)),
+			Evidence:  skillEvidence(sentences, matched),
+		})
+	}
+	if len(job.NiceToHaveSkills) > 0 {
+		var matched []string
+		for _, skill := range job.NiceToHaveSkills {
+			if hasSkill(skill) {
+				matched = append(matched, skill)
+			}
+		}
+		result.MatchedSkills = append(result.MatchedSkills, matched...)
+		result.Breakdown = append(result.Breakdown, ScoreComponent{
+			Component: ComponentNiceToHaveSkills,
+			Weight:    rubric.NiceToHaveSkills,
+			Match:     float64(len(matched)) / float64(len(job.NiceToHaveSkills)),
+			Detail:    fmt.Sprintf("%d of %d nice-to-have skills", len(matched), len(job.NiceToHaveSkills)),
+			Evidence:  skillEvidence(sentences, matched),
+		})
+	}
+	if job.MinYears > 0 {
+		result.Breakdown = append(result.Breakdown, ScoreComponent{
+			Component: ComponentExperience,
+			Weight:    rubric.Experience,
+			Match:     math.Min(1, float64(result.YearsOfExperience)/float64(job.MinYears)),
+			Detail:    fmt.Sprintf("about %d years of %d asked", result.YearsOfExperience, job.MinYears),
+			Evidence: findEvidence(sentences, func(sentence string) int {
+				if loc := yearsStatedPattern.FindStringIndex(sentence); loc != nil {
+					return loc[0]
+				}
+				if loc := yearRangePattern.FindStringSubmatchIndex(sentence); loc != nil {
+					return loc[2]
+				}
+				return -1
+			}),
+		})
+	}
+	if wanted := educationRank(job.MinEducation); wanted > 0 {
+		result.EducationLevel = HighestEducationLevel(clean)
+		detail := "no degree found, " + job.MinEducation + " asked"
+		if result.EducationLevel != "" {
+			detail = result.EducationLevel + ", " + job.MinEducation + " asked"
+		}
+		result.Breakdown = append(result.Breakdown, ScoreComponent{
+			Component: ComponentEducation,
+			Weight:    rubric.Education,
+			Match:     math.Min(1, float64(educationRank(result.EducationLevel))/float64(wanted)),
+			Detail:    detail,
+			Evidence:  termEvidence(sentences, educationTerms[result.EducationLevel]),
+		})
+	}
+	if len(job.Keywords) > 0 {
+		// Whole words only, so "java" is not found in "javascript"
+		mentioned := countSkills(newSkillMatcher(job.Keywords).find(text))
+		var found []string
+		for _, keyword := range job.Keywords {
+			if mentioned[textutil.CanonicalSkill(keyword)].mentions > 0 {
+				found = append(found, keyword)
+			}
+		}
+		result.Breakdown = append(result.Breakdown, ScoreComponent{
+			Component: ComponentKeywords,
+			Weight:    rubric.Keywords,
+			Match:     float64(len(found)) / float64(len(job.Keywords)),
+			Detail:    fmt.Sprintf("%d of %d job keywords", len(found), len(job.Keywords)),
+			Evidence:  skillEvidence(sentences, found),
+		})
+	}
+
+	// Share the 100 points among the weighted components
+	var weight, score float64
+	for _, component := range result.Breakdown {
+		weight += component.Weight
+	}
+	for i := range result.Breakdown {
+		component := &result.Breakdown[i]
+		component.Match = math.Round(component.Match*100) / 100
+		if weight > 0 {
+			points := 100 * component.Weight * component.Match / weight
+			component.Points = math.Round(points*10) / 10
+			score += points
+		}
+		if component.Evidence == nil {
+			component.Evidence = []string{}
+		}
+	}
+	result.Score = int(math.Round(score))
+	return result
+}
+
+// EstimateYearsOfExperience reads years of experience from resume text:
+// the largest "N years" stated, or else the span of the year ranges listed
+func EstimateYearsOfExperience(text string) int {
+	years := 0
+	for _, match := range yearsStatedPattern.FindAllStringSubmatch(text, -1) {
+		if n, err := strconv.Atoi(match[1]); err == nil && n > years {
+			years = n
+		}
+	}
+	if years > 0 {
+		return years
+	}
+
+	first, last := 0, 0
+	now := time.Now().Year()
+	for _, match := range yearRangePattern.FindAllStringSubmatch(text, -1) {
+		start, _ := strconv.Atoi(match[1])
+		end, err := strconv.Atoi(match[2])
+		if err != nil {
+			end = now // present, current or now
+		}
+		if start > end || end > now {
+			continue
+		}
+		if first == 0 || start < first {
+			first = start
+		}
+		if end > last {
+			last = end
+		}
+	}
+	return last - first
+}
+
+// ValidEducationLevel reports whether level is one of EducationLevels
+func ValidEducationLevel(level string) bool {
+	return educationRank(level) > 0
+}
+
+// HighestEducationLevel returns the highest education level text names, or
+// an empty string when it names none
+func HighestEducationLevel(text string) string {
+	text = cleanText(text)
+	for i := len(EducationLevels) - 1; i >= 0; i-- {
+		if containsAny(text, educationTerms[EducationLevels[i]]) {
+			return EducationLevels[i]
+		}
+	}
+	return ""
+}
+
+// LowestEducationLevel returns the lowest education level text names, which
+// is what a job description asking for "a bachelor's or master's" requires
+func LowestEducationLevel(text string) string {
+	text = cleanText(text)
+	for _, level := range EducationLevels {
+		if containsAny(text, educationTerms[level]) {
+			return level
+		}
+	}
+	return ""
+}
+
+// educationRank returns the position of level in EducationLevels counting
+// from 1, or 0 when it is not one
+func educationRank(level string) int {
+	for i, known := range EducationLevels {
+		if level == known {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// containsAny reports whether text contains any of terms
+func containsAny(text string, terms []string) bool {
+	for _, term := range terms {
+		if strings.Contains(text, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSentences splits resume text into sentences and list items with
+// their whitespace collapsed
+func splitSentences(text string) []string {
+	var sentences []string
+	for _, sentence := range sentenceBreak.Split(text, -1) {
+		sentence = strings.TrimSpace(whitespaceRun.ReplaceAllString(sentence, " "))
+		if len(sentence) >= minSentenceLength {
+			sentences = append(sentences, sentence)
+		}
+	}
+	return sentences
+}
+
+// skillEvidence returns the sentences naming any of skills, under any of
+// their names
+func skillEvidence(sentences []string, skills []string) []string {
+	if len(skills) == 0 {
+		return nil
+	}
+	matcher := newSkillMatcher(skills)
+	return findEvidence(sentences, func(sentence string) int {
+		if mentions := matcher.find(sentence); len(mentions) > 0 {
+			return mentions[0].start
+		}
+		return -1
+	})
+}
+
+// termEvidence returns the sentences containing any of terms
+func termEvidence(sentences []string, terms []string) []string {
+	if len(terms) == 0 {
+		return nil
+	}
+	return findEvidence(sentences, func(sentence string) int {
+		lower := strings.ToLower(sentence)
+		first := -1
+		for _, term := range terms {
+			if i := strings.Index(lower, strings.ToLower(term)); i >= 0 && (first < 0 || i < first) {
+				first = i
+			}
+		}
+		return first
+	})
+}
+
+// findEvidence returns up to maxEvidence sentences for which match returns
+// the offset of what they show, or -1 when they show nothing. Long
+// sentences are cut down around that offset.
+func findEvidence(sentences []string, match func(string) int) []string {
+	var evidence []string
+	seen := make(map[string]bool)
+	for _, sentence := range sentences {
+		at := match(sentence)
+		if at < 0 {
+			continue
+		}
+		excerpt := excerpt(sentence, at)
+		if seen[excerpt] {
+			continue
+		}
+		seen[excerpt] = true
+		evidence = append(evidence, excerpt)
+		if len(evidence) == maxEvidence {
+			break
+		}
+	}
+	return evidence
+}
+
+// excerpt cuts a sentence down to maxEvidenceLength bytes around offset at,
+// marking cuts with an ellipsis
+func excerpt(sentence string, at int) string {
+	if len(sentence) <= maxEvidenceLength {
+		return sentence
+	}
+	start := at - evidenceLeadingText
+	if start < 0 || at > len(sentence) {
+		start = 0
+	}
+	end := start + maxEvidenceLength
+	if end > len(sentence) {
+		end, start = len(sentence), len(sentence)-maxEvidenceLength
+	}
+
+	// Do not cut runes in half
+	for start > 0 && !isRuneStart(sentence[start]) {
+		start--
+	}
+	for end < len(sentence) && !isRuneStart(sentence[end]) {
+		end--
+	}
+	cut := sentence[start:end]
+	if start > 0 {
+		cut = "…" + cut
+	}
+	if end < len(sentence) {
+		cut += "…"
+	}
+	return cut
+}
+
+// isRuneStart reports whether b is the first byte of a UTF-8 sequence
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// component returns the named part of a match's breakdown, or nil
+func component(result MatchResult, name string) *ScoreComponent {
+	for i := range result.Breakdown {
+		if result.Breakdown[i].Component == name {
+			return &result.Breakdown[i]
+		}
+	}
+	return nil
+}
+
+func TestMatchKeywords(t *testing.T) {
+	tests := []struct {
+		name     string
+		keywords []string
+		text     string
+		want     float64 // Share of keywords found
+		evidence []string
+	}{
+		{"whole word", []string{"java"}, "Five years of Java. Also Go.", 1, []string{"Five years of Java"}},
+		{"java is not javascript", []string{"java"}, "Frontend work in JavaScript", 0, nil},
+		{"react is not reactive", []string{"react"}, "Built reactive pipelines", 0, nil},
+		{"phrase", []string{"payment systems"}, "Designed payment systems for banks", 1, []string{"Designed payment systems for banks"}},
+		{"phrase words apart", []string{"payment systems"}, "Payment and billing systems", 0, nil},
+		{"skill under an alias", []string{"kubernetes"}, "Ran k8s clusters", 1, []string{"Ran k8s clusters"}},
+		{"some of several", []string{"fintech", "banking", "java", "react"}, "Java in fintech", 0.5, []string{"Java in fintech"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := MatchResume(JobRequirements{Keywords: tt.keywords}, nil, tt.text)
+			keywords := component(result, ComponentKeywords)
+			if keywords == nil {
+				t.Fatal("no keywords component")
+			}
+			if keywords.Match != tt.want {
+				t.Errorf("match = %v, want %v (%s)", keywords.Match, tt.want, keywords.Detail)
+			}
+			if strings.Join(keywords.Evidence, "|") != strings.Join(tt.evidence, "|") {
+				t.Errorf("evidence = %q, want %q", keywords.Evidence, tt.evidence)
+			}
+		})
+	}
+}
+
+func TestEstimateYearsOfExperience(t *testing.T) {
+	now := time.Now().Year()
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"stated", "7 years of experience", 7},
+		{"largest stated", "3 years of Go, 10+ years overall", 10},
+		{"yrs", "5 yrs in backend", 5},
+		{"three digits are not years", "100 years of history", 0},
+		{"stated wins over ranges", "2 years as lead. Acme 2010 - 2020", 2},
+		{"range", "Acme 2015 - 2020", 5},
+		{"span of ranges", "Acme 2012–2015\nInitech 2018-2020", 8},
+		{"to present", "Acme 2019 - Present", now - 2019},
+		{"no space before the year", "Acme2016-2019", 3},
+		{"backwards range", "2020 - 2018", 0},
+		{"future range", "2019 - " + strconv.Itoa(now+5), 0},
+		{"nothing", "Go developer", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateYearsOfExperience(tt.text); got != tt.want {
+				t.Errorf("EstimateYearsOfExperience(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEducationLevels(t *testing.T) {
+	tests := []struct {
+		text    string
+		highest string
+		lowest  string
+	}{
+		{"B.Sc in CS, M.Sc in AI", "master", "bachelor"},
+		{"PhD candidate", "phd", "phd"},
+		{"Bachelor of Arts", "bachelor", "bachelor"},
+		{"Associate degree in nursing", "associate", "associate"},
+		{"A bachelor's or master's degree", "master", "bachelor"},
+		{"MBA, Doctorate in economics", "phd", "master"},
+		{"High school", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := HighestEducationLevel(tt.text); got != tt.highest {
+				t.Errorf("HighestEducationLevel = %q, want %q", got, tt.highest)
+			}
+			if got := LowestEducationLevel(tt.text); got != tt.lowest {
+				t.Errorf("LowestEducationLevel = %q, want %q", got, tt.lowest)
+			}
+		})
+	}
+}
+
+func TestMatchResume(t *testing.T) {
+	job := JobRequirements{
+		RequiredSkills:   []string{"go", "docker"},
+		NiceToHaveSkills: []string{"kubernetes"},
+		MinYears:         4,
+		MinEducation:     "bachelor",
+		Keywords:         []string{"fintech"},
+	}
+	result := MatchResume(job, nil, "Go developer with 2 years in fintech. BSc Computer Science.")
+
+	// Default weights 40, 20, 15, 10 and 15 times 0.5, 0, 0.5, 1 and 1
+	want := map[string]float64{
+		ComponentRequiredSkills:   20,
+		ComponentNiceToHaveSkills: 0,
+		ComponentExperience:       7.5,
+		ComponentEducation:        10,
+		ComponentKeywords:         15,
+	}
+	if len(result.Breakdown) != len(want) {
+		t.Fatalf("breakdown = %+v", result.Breakdown)
+	}
+	for name, points := range want {
+		if got := component(result, name); got == nil || got.Points != points {
+			t.Errorf("%s = %+v, want %v points", name, got, points)
+		}
+	}
+	if result.Score != 53 {
+		t.Errorf("score = %d, want 53", result.Score)
+	}
+	if strings.Join(result.MatchedSkills, ",") != "go" || strings.Join(result.MissingRequiredSkills, ",") != "docker" {
+		t.Errorf("matched %v, missing %v", result.MatchedSkills, result.MissingRequiredSkills)
+	}
+	if result.YearsOfExperience != 2 || result.EducationLevel != "bachelor" {
+		t.Errorf("years = %d, education = %q", result.YearsOfExperience, result.EducationLevel)
+	}
+}
+
+func TestMatchResumeSkillSources(t *testing.T) {
+	job := JobRequirements{RequiredSkills: []string{"Golang", "docker"}}
+	tests := []struct {
+		name   string
+		skills []string
+		text   string
+		want   int
+	}{
+		{"named in text", nil, "Go and Docker", 100},
+		{"under an alias", nil, "golang, docker", 100},
+		{"half", nil, "Go only", 50},
+		// Stored skills only stand in for a missing text
+		{"stored skills without text", []string{"go", "docker"}, "", 100},
+		{"stored skills with text", []string{"go", "docker"}, "Java developer", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchResume(job, tt.skills, tt.text).Score; got != tt.want {
+				t.Errorf("score = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchResumeRubric(t *testing.T) {
+	job := JobRequirements{RequiredSkills: []string{"go"}, MinYears: 10}
+	text := "Go developer for 5 years"
+	tests := []struct {
+		name   string
+		rubric Rubric
+		skills float64 // Points for required skills
+		years  float64 // Points for experience
+		score  int
+	}{
+		// Components the job does not ask for are left out and the
+		// others scaled up to 100
+		{"rescaled", Rubric{RequiredSkills: 30, Experience: 10, Education: 50}, 75, 12.5, 88},
+		{"zero weight", Rubric{Experience: 10}, 0, 50, 50},
+		{"all zero", Rubric{}, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job.Rubric = &tt.rubric
+			result := MatchResume(job, nil, text)
+			if len(result.Breakdown) != 2 {
+				t.Fatalf("breakdown = %+v, want skills and experience", result.Breakdown)
+			}
+			skills, years := component(result, ComponentRequiredSkills), component(result, ComponentExperience)
+			if skills.Points != tt.skills || years.Points != tt.years {
+				t.Errorf("points = %v and %v, want %v and %v", skills.Points, years.Points, tt.skills, tt.years)
+			}
+			if skills.Match != 1 || years.Match != 0.5 {
+				t.Errorf("match = %v and %v, want 1 and 0.5", skills.Match, years.Match)
+			}
+			if result.Score != tt.score {
+				t.Errorf("score = %d, want %d", result.Score, tt.score)
+			}
+		})
+	}
+
+	// Without a rubric of its own the job uses DefaultRubric
+	job.Rubric = nil
+	if got := component(MatchResume(job, nil, text), ComponentRequiredSkills).Weight; got != DefaultRubric.RequiredSkills {
+		t.Errorf("weight = %v, want the default %v", got, DefaultRubric.RequiredSkills)
+	}
+}
+
+func TestMatchResumeEvidence(t *testing.T) {
+	job := JobRequirements{RequiredSkills: []string{"go", "docker", "rust"}}
+	long := "Worked on " + strings.Repeat("many internal systems, ", 12) + "mostly in Go and more " + strings.Repeat("filler ", 30)
+	text := "Go developer.\n• Docker images\n• Docker compose\nGo again. Golang at work. " + long
+
+	skills := component(MatchResume(job, nil, text), ComponentRequiredSkills)
+	if skills.Match != 0.67 {
+		t.Errorf("match = %v, want 0.67", skills.Match)
+	}
+
+	// At most maxEvidence sentences, in order
+	want := []string{"Go developer", "Docker images", "Docker compose"}
+	if strings.Join(skills.Evidence, "|") != strings.Join(want, "|") {
+		t.Errorf("evidence = %q, want %q", skills.Evidence, want)
+	}
+
+	// Long sentences are cut around the match
+	cut := component(MatchResume(JobRequirements{RequiredSkills: []string{"go"}}, nil, long), ComponentRequiredSkills).Evidence
+	if len(cut) != 1 || !strings.HasPrefix(cut[0], "…") || !strings.HasSuffix(cut[0], "…") || !strings.Contains(cut[0], "mostly in Go") {
+		t.Errorf("evidence = %q, want an excerpt around the match", cut)
+	}
+	if len(cut) == 1 && len(cut[0]) > maxEvidenceLength+2*len("…") {
+		t.Errorf("excerpt is %d bytes", len(cut[0]))
+	}
+}
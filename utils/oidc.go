@@ -0,0 +1,384 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// OIDCLoginTTL is how long a user has to finish signing in at the IdP
+const OIDCLoginTTL = 10 * time.Minute
+
+// maxPendingLogins caps the logins waiting for the IdP to call back, since
+// anyone can start one. Past it the oldest are dropped.
+var maxPendingLogins = 10000
+
+// jwksRefreshInterval limits how often an unknown kid triggers a JWKS fetch
+const jwksRefreshInterval = time.Minute
+
+// OIDC errors
+var (
+	ErrOIDCState   = errors.New("unknown or expired OIDC login state")
+	ErrOIDCIDToken = errors.New("invalid OIDC ID token")
+)
+
+// OIDCConfig configures the OpenID Connect relying party
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // Optional; public clients rely on PKCE alone
+	RedirectURL  string
+	Scopes       []string
+}
+
+// OIDCIdentity is the verified identity from an ID token
+type OIDCIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+}
+
+// oidcLogin is a login started by AuthCodeURL and not yet completed
+type oidcLogin struct {
+	nonce     string
+	verifier  string
+	expiresAt time.Time
+}
+
+// idTokenClaims are the ID token claims we use
+type idTokenClaims struct {
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	jwt.RegisteredClaims
+}
+
+// OIDCProvider signs users in with an OpenID Connect identity provider
+// using the authorization code flow with PKCE
+type OIDCProvider struct {
+	config OIDCConfig
+	client *http.Client
+
+	// Issuer and endpoints from the discovery document
+	issuer   string
+	authURL  string
+	tokenURL string
+	jwksURL  string
+
+	mutex       sync.Mutex
+	keys        map[string]*rsa.PublicKey // kid -> key
+	keysFetched time.Time
+	pending     map[string]*oidcLogin // state -> login
+}
+
+// NewOIDCProvider fetches the issuer's discovery document and returns a
+// provider for it. A nil client uses http.DefaultClient.
+func NewOIDCProvider(ctx context.Context, config OIDCConfig, client *http.Client) (*OIDCProvider, error) {
+	if config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("OIDC issuer, client ID and redirect URL are required")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+
+	// Discover the endpoints
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := getJSON(ctx, client, config.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("OIDC discovery: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != config.Issuer {
+		return nil, fmt.Errorf("OIDC discovery returned issuer %q, expected %q", discovery.Issuer, config.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing endpoints")
+	}
+
+	return &OIDCProvider{
+		config:   config,
+		client:   client,
+		issuer:   discovery.Issuer,
+		authURL:  discovery.AuthorizationEndpoint,
+		tokenURL: discovery.TokenEndpoint,
+		jwksURL:  discovery.JWKSURI,
+		keys:     make(map[string]*rsa.PublicKey),
+		pending:  make(map[string]*oidcLogin),
+	}, nil
+}
+
+// LoadOIDCProviderFromEnv configures OIDC from OIDC_ISSUER, OIDC_CLIENT_ID,
+// OIDC_CLIENT_SECRET and OIDC_REDIRECT_URL. It returns nil when
+// OIDC_ISSUER is not set.
+func LoadOIDCProviderFromEnv(ctx context.Context) (*OIDCProvider, error) {
+	if os.Getenv("OIDC_ISSUER") == "" {
+		return nil, nil
+	}
+	return NewOIDCProvider(ctx, OIDCConfig{
+		Issuer:       os.Getenv("OIDC_ISSUER"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+	}, nil)
+}
+
+// Issuer returns the identity provider's issuer URL
+func (p *OIDCProvider) Issuer() string {
+	return p.issuer
+}
+
+// AuthCodeURL starts a login and returns the IdP URL to send the user to
+// and the login's state. Callers bind the state to the browser, e.g. in a
+// cookie, and check it comes back from the same browser before Exchange.
+func (p *OIDCProvider) AuthCodeURL() (string, string, error) {
+	state, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	p.mutex.Lock()
+	now := time.Now()
+	for s, login := range p.pending {
+		if login.expiresAt.Before(now) {
+			delete(p.pending, s)
+		}
+	}
+	for len(p.pending) >= maxPendingLogins {
+		oldest := ""
+		for s, login := range p.pending {
+			if oldest == "" || login.expiresAt.Before(p.pending[oldest].expiresAt) {
+				oldest = s
+			}
+		}
+		delete(p.pending, oldest)
+	}
+	p.pending[state] = &oidcLogin{nonce: nonce, verifier: verifier, expiresAt: now.Add(OIDCLoginTTL)}
+	p.mutex.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(p.authURL, "?") {
+		separator = "&"
+	}
+	return p.authURL + separator + params.Encode(), state, nil
+}
+
+// Exchange completes a login: it redeems the authorization code for an ID
+// token and returns the verified identity in it. Each state works once.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, state string) (*OIDCIdentity, error) {
+	p.mutex.Lock()
+	login, exists := p.pending[state]
+	delete(p.pending, state)
+	p.mutex.Unlock()
+	if !exists || time.Now().After(login.expiresAt) {
+		return nil, ErrOIDCState
+	}
+
+	// Redeem the code
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {login.verifier},
+	}
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OIDC token endpoint returned %s", resp.Status)
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, err
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no id_token", ErrOIDCIDToken)
+	}
+
+	// Verify the ID token
+	claims, err := p.verifyIDToken(ctx, tokens.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Nonce != login.nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrOIDCIDToken)
+	}
+
+	return &OIDCIdentity{
+		Issuer:        p.issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Username:      claims.PreferredUsername,
+	}, nil
+}
+
+// verifyIDToken checks an ID token's RS256 signature against the IdP's
+// JWKS, and its issuer, audience and expiry
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, raw string) (*idTokenClaims, error) {
+	claims := &idTokenClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256"}))
+	_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCIDToken, err)
+	}
+
+	if !claims.VerifyIssuer(p.issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrOIDCIDToken, claims.Issuer)
+	}
+	if !claims.VerifyAudience(p.config.ClientID, true) {
+		return nil, fmt.Errorf("%w: token was issued for another client", ErrOIDCIDToken)
+	}
+	if claims.ExpiresAt == nil || claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing exp or sub", ErrOIDCIDToken)
+	}
+	return claims, nil
+}
+
+// publicKey returns the IdP signing key named kid, refetching the JWKS
+// when the key is unknown so IdP key rotation is picked up
+func (p *OIDCProvider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < jwksRefreshInterval {
+		return nil, errors.New("unknown signing key")
+	}
+
+	keys, err := fetchJWKS(ctx, p.client, p.jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// fetchJWKS downloads a JSON Web Key Set and returns its RSA signing keys
+func fetchJWKS(ctx context.Context, client *http.Client, jwksURL string) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, client, jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %q: %w", jwk.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+// getJSON fetches url and decodes the JSON response into v
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// randomToken returns 32 random bytes, base64url encoded
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// mockIdP is an OpenID Connect provider serving discovery, JWKS and a token
+// endpoint that issues ID tokens for the last authorization request
+type mockIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	// From the authorization request, as the IdP would remember them
+	nonce     string
+	challenge string
+
+	// Changes the ID token claims before signing
+	modify func(claims jwt.MapClaims)
+}
+
+// newMockIdP starts a mock provider; it stops when the test ends
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{t: t, key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test-key",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// authorize plays the user signing in: it records what the IdP keeps from
+// the authorization request and returns the state to call back with
+func (idp *mockIdP) authorize(authURL string) string {
+	idp.t.Helper()
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		idp.t.Fatalf("code_challenge_method = %q", query.Get("code_challenge_method"))
+	}
+	idp.nonce = query.Get("nonce")
+	idp.challenge = query.Get("code_challenge")
+	return query.Get("state")
+}
+
+// token redeems the code "good-code" for a signed ID token
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("code") != "good-code" {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	// PKCE: the verifier must hash to the challenge from the auth request
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != idp.challenge {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":                idp.server.URL,
+		"aud":                "client-id",
+		"sub":                "subject-1",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              idp.nonce,
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+	}
+	if idp.modify != nil {
+		idp.modify(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		idp.t.Error(err)
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+}
+
+// provider returns a relying party configured for the mock provider
+func (idp *mockIdP) provider() *OIDCProvider {
+	idp.t.Helper()
+	provider, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		Issuer:      idp.server.URL,
+		ClientID:    "client-id",
+		RedirectURL: "http://localhost/api/oidc/callback",
+	}, idp.server.Client())
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	return provider
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := idp.provider()
+
+	authURL, state, err := provider.AuthCodeURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := idp.authorize(authURL); got != state {
+		t.Fatalf("auth URL state = %q, AuthCodeURL returned %q", got, state)
+	}
+
+	identity, err := provider.Exchange(context.Background(), "good-code", state)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := OIDCIdentity{
+		Issuer:        idp.server.URL,
+		Subject:       "subject-1",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Username:      "alice",
+	}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+
+	// Each state works once
+	if _, err := provider.Exchange(context.Background(), "good-code", state); !errors.Is(err, ErrOIDCState) {
+		t.Errorf("reused state: err = %v, want ErrOIDCState", err)
+	}
+}
+
+func TestOIDCExchangeRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		modify func(claims jwt.MapClaims)
+		want   error
+	}{
+		{"unknown code", "bad-code", nil, nil},
+		{"other audience", "good-code", func(c jwt.MapClaims) { c["aud"] = "other-client" }, ErrOIDCIDToken},
+		{"other issuer", "good-code", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, ErrOIDCIDToken},
+		{"expired", "good-code", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, ErrOIDCIDToken},
+		{"nonce mismatch", "good-code", func(c jwt.MapClaims) { c["nonce"] = "replayed" }, ErrOIDCIDToken},
+		{"no subject", "good-code", func(c jwt.MapClaims) { delete(c, "sub") }, ErrOIDCIDToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			idp.modify = tt.modify
+			provider := idp.provider()
+
+			authURL, _, err := provider.AuthCodeURL()
+			if err != nil {
+				t.Fatal(err)
+			}
+			state := idp.authorize(authURL)
+
+			_, err = provider.Exchange(context.Background(), tt.code, state)
+			if err == nil {
+				t.Fatal("Exchange succeeded, want an error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeUnknownState(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := idp.provider()
+
+	if _, err := provider.Exchange(context.Background(), "good-code", "never-issued"); !errors.Is(err, ErrOIDCState) {
+		t.Errorf("err = %v, want ErrOIDCState", err)
+	}
+}
+
+func TestOIDCPendingLoginsCapped(t *testing.T) {
+	limit := maxPendingLogins
+	maxPendingLogins = 3
+	t.Cleanup(func() { maxPendingLogins = limit })
+
+	idp := newMockIdP(t)
+	provider := idp.provider()
+
+	var states []string
+	for i := 0; i < 5; i++ {
+		authURL, state, err := provider.AuthCodeURL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		idp.authorize(authURL)
+		states = append(states, state)
+	}
+	if len(provider.pending) != maxPendingLogins {
+		t.Errorf("%d pending logins, want %d", len(provider.pending), maxPendingLogins)
+	}
+
+	// The oldest logins were dropped; the newest still completes
+	if _, err := provider.Exchange(context.Background(), "good-code", states[0]); !errors.Is(err, ErrOIDCState) {
+		t.Errorf("oldest login: err = %v, want ErrOIDCState", err)
+	}
+	if _, err := provider.Exchange(context.Background(), "good-code", states[4]); err != nil {
+		t.Errorf("newest login: %v", err)
+	}
+}
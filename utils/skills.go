@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+
+	"webscrapper/textutil"
+)
+
+// skillTaxonomy returns commonSkills and extraSkills under their taxonomy
+// names, without duplicates
+func skillTaxonomy(extraSkills []string) []string {
+	var skills []string
+	seen := make(map[string]bool)
+	for _, list := range [][]string{commonSkills, extraSkills} {
+		for _, skill := range list {
+			skill = textutil.CanonicalSkill(skill)
+			if skill == "" || seen[skill] {
+				continue
+			}
+			seen[skill] = true
+			skills = append(skills, skill)
+		}
+	}
+	return skills
+}
+
+// skillPhrase is one name of a skill as the words it consists of
+type skillPhrase struct {
+	skill string
+	name  string
+	words []string
+}
+
+// skillMention is a skill named in text
+type skillMention struct {
+	skill string
+	name  string // The name used
+	start int    // Byte offset in the text
+}
+
+// skillMatcher finds skills in text by whole words, under their own name
+// or any alias
+type skillMatcher struct {
+	phrases map[string][]skillPhrase // First word -> phrases, longest first
+}
+
+// newSkillMatcher returns a matcher for skills, given under any of their
+// names
+func newSkillMatcher(skills []string) *skillMatcher {
+	m := &skillMatcher{phrases: make(map[string][]skillPhrase)}
+	seen := make(map[string]bool)
+	for _, skill := range skills {
+		skill = textutil.CanonicalSkill(skill)
+		if skill == "" || seen[skill] {
+			continue
+		}
+		seen[skill] = true
+		for _, name := range append([]string{skill}, textutil.SkillAliases(skill)...) {
+			var words []string
+			for _, token := range textutil.Tokenize(name) {
+				words = append(words, token.Text)
+			}
+			if len(words) > 0 {
+				m.phrases[words[0]] = append(m.phrases[words[0]], skillPhrase{skill: skill, name: name, words: words})
+			}
+		}
+	}
+	for _, phrases := range m.phrases {
+		sort.SliceStable(phrases, func(i, j int) bool {
+			return len(phrases[i].words) > len(phrases[j].words)
+		})
+	}
+	return m
+}
+
+// find returns the skills text names, in order. Where names overlap the
+// longest wins, so "rest apis" is one mention rather than two.
+func (m *skillMatcher) find(text string) []skillMention {
+	tokens := textutil.Tokenize(text)
+	words := make([]string, len(tokens))
+	for i, token := range tokens {
+		words[i] = strings.ToLower(token.Text)
+	}
+
+	var mentions []skillMention
+	for i := 0; i < len(words); {
+		phrase, ok := m.phraseAt(words, i)
+		if !ok {
+			i++
+			continue
+		}
+		mentions = append(mentions, skillMention{skill: phrase.skill, name: phrase.name, start: tokens[i].Start})
+		i += len(phrase.words)
+	}
+	return mentions
+}
+
+// phraseAt returns the longest phrase whose words start at words[i]
+func (m *skillMatcher) phraseAt(words []string, i int) (skillPhrase, bool) {
+	for _, phrase := range m.phrases[words[i]] {
+		if i+len(phrase.words) > len(words) {
+			continue
+		}
+		matched := true
+		for j, word := range phrase.words[1:] {
+			if words[i+1+j] != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return phrase, true
+		}
+	}
+	return skillPhrase{}, false
+}
+
+// skillCount is how often text names a skill, and the name it uses most
+type skillCount struct {
+	mentions int
+	term     string
+}
+
+// countSkills tallies mentions by skill. Ties between names go to the one
+// used first.
+func countSkills(mentions []skillMention) map[string]skillCount {
+	byName := make(map[string]int)
+	counts := make(map[string]skillCount)
+	for _, mention := range mentions {
+		byName[mention.name]++
+		count := counts[mention.skill]
+		count.mentions++
+		if count.term == "" || byName[mention.name] > byName[count.term] {
+			count.term = mention.name
+		}
+		counts[mention.skill] = count
+	}
+	return counts
+}
@@ -0,0 +1,310 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Attempts is the failure history of one throttle key
+type Attempts struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// AttemptStore keeps login failure counts. MemoryAttemptStore works for a
+// single process; several instances behind a load balancer need a shared
+// implementation so they see each other's failures.
+type AttemptStore interface {
+	// Get returns the attempts recorded under key
+	Get(key string) (Attempts, error)
+	// RecordFailure adds a failure under key and returns the updated
+	// attempts. Failures older than window are forgotten first.
+	RecordFailure(key string, now time.Time, window time.Duration) (Attempts, error)
+	// Lock blocks key until the given time
+	Lock(key string, until time.Time) error
+	// Reset forgets everything recorded under key
+	Reset(key string) error
+}
+
+// MemoryAttemptStore is an in-process AttemptStore
+type MemoryAttemptStore struct {
+	mutex     sync.Mutex
+	attempts  map[string]*Attempts
+	lastSweep time.Time
+}
+
+// NewMemoryAttemptStore creates an empty in-process attempt store
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{attempts: make(map[string]*Attempts)}
+}
+
+// Get returns the attempts recorded under key
+func (s *MemoryAttemptStore) Get(key string) (Attempts, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if attempts, exists := s.attempts[key]; exists {
+		return *attempts, nil
+	}
+	return Attempts{}, nil
+}
+
+// RecordFailure adds a failure under key and returns the updated attempts
+func (s *MemoryAttemptStore) RecordFailure(key string, now time.Time, window time.Duration) (Attempts, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Drop stale entries now and then so the map does not grow forever
+	if now.Sub(s.lastSweep) > window {
+		for k, attempts := range s.attempts {
+			if now.Sub(attempts.LastFailure) > window && now.After(attempts.LockedUntil) {
+				delete(s.attempts, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	attempts, exists := s.attempts[key]
+	if !exists {
+		attempts = &Attempts{}
+		s.attempts[key] = attempts
+	}
+	if now.Sub(attempts.LastFailure) > window {
+		attempts.Failures = 0
+	}
+	attempts.Failures++
+	attempts.LastFailure = now
+	return *attempts, nil
+}
+
+// Lock blocks key until the given time
+func (s *MemoryAttemptStore) Lock(key string, until time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	attempts, exists := s.attempts[key]
+	if !exists {
+		attempts = &Attempts{}
+		s.attempts[key] = attempts
+	}
+	attempts.LockedUntil = until
+	return nil
+}
+
+// Reset forgets everything recorded under key
+func (s *MemoryAttemptStore) Reset(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+// ThrottlePolicy limits failed attempts for one kind of key
+type ThrottlePolicy struct {
+	MaxFailures int           // Failures within Window before a lockout
+	BaseDelay   time.Duration // Wait after the first failure, doubled for each further one
+	MaxDelay    time.Duration // Cap on the backoff delay
+	Lockout     time.Duration // How long a lockout lasts
+	Window      time.Duration // Failures older than this are forgotten
+}
+
+// delay returns the backoff after the given number of failures
+func (p ThrottlePolicy) delay(failures int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// Default throttle policies. Usernames lock out quickly; an IP address may
+// legitimately carry many users (offices, NAT) so it gets more room.
+var (
+	DefaultUserPolicy = ThrottlePolicy{
+		MaxFailures: 5,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+		Lockout:     15 * time.Minute,
+		Window:      15 * time.Minute,
+	}
+	DefaultIPPolicy = ThrottlePolicy{
+		MaxFailures: 50,
+		BaseDelay:   0,
+		MaxDelay:    0,
+		Lockout:     15 * time.Minute,
+		Window:      15 * time.Minute,
+	}
+)
+
+// pendingRetry is how long to wait when attempts still in flight could
+// end in a lockout, since their outcome is not known yet
+const pendingRetry = time.Second
+
+// LoginThrottle applies exponential backoff and temporary lockout to
+// login attempts, tracked per username and per client IP
+type LoginThrottle struct {
+	store      AttemptStore
+	userPolicy ThrottlePolicy
+	ipPolicy   ThrottlePolicy
+
+	// Reserve and the calls ending an attempt run under mutex, so
+	// concurrent attempts cannot all pass the check before any failure is
+	// recorded. pending counts the reserved attempts per key; it is local
+	// to this process, like the mutex.
+	mutex   sync.Mutex
+	pending map[string]int
+
+	// OnLockout is called whenever a key gets locked out
+	OnLockout func(kind, subject string, until time.Time)
+}
+
+// NewLoginThrottle creates a throttle with the given store and policies
+func NewLoginThrottle(store AttemptStore, userPolicy, ipPolicy ThrottlePolicy) *LoginThrottle {
+	return &LoginThrottle{store: store, userPolicy: userPolicy, ipPolicy: ipPolicy, pending: make(map[string]int)}
+}
+
+// throttleKeys returns the store keys of a login attempt
+func throttleKeys(username, ip string) (userKey, ipKey string) {
+	return "user:" + strings.ToLower(username), "ip:" + ip
+}
+
+// Reserve checks whether an attempt for username from ip may proceed and,
+// if so, reserves it in the same step. It returns how long the caller must
+// wait, or zero once the attempt is reserved. Every reserved attempt must
+// end with Failure, Success or Release.
+//
+// Reserved attempts count as failures happening now until they end, so
+// parallel guesses get the same backoff as sequential ones.
+func (t *LoginThrottle) Reserve(username, ip string) (time.Duration, error) {
+	userKey, ipKey := throttleKeys(username, ip)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	now := time.Now()
+
+	var wait time.Duration
+	for _, check := range []struct {
+		key    string
+		policy ThrottlePolicy
+	}{{userKey, t.userPolicy}, {ipKey, t.ipPolicy}} {
+		attempts, err := t.store.Get(check.key)
+		if err != nil {
+			return 0, err
+		}
+		if attempts.LockedUntil.After(now) {
+			wait = maxDuration(wait, attempts.LockedUntil.Sub(now))
+		}
+		failures := 0
+		if attempts.Failures > 0 && now.Sub(attempts.LastFailure) <= check.policy.Window {
+			failures = attempts.Failures
+			next := attempts.LastFailure.Add(check.policy.delay(failures))
+			if next.After(now) {
+				wait = maxDuration(wait, next.Sub(now))
+			}
+		}
+		if pending := t.pending[check.key]; pending > 0 {
+			if failures+pending >= check.policy.MaxFailures {
+				wait = maxDuration(wait, pendingRetry)
+			}
+			wait = maxDuration(wait, check.policy.delay(failures+pending))
+		}
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+
+	t.pending[userKey]++
+	t.pending[ipKey]++
+	return 0, nil
+}
+
+// release ends a reserved attempt. Callers hold the lock.
+func (t *LoginThrottle) release(keys ...string) {
+	for _, key := range keys {
+		if t.pending[key] <= 1 {
+			delete(t.pending, key)
+		} else {
+			t.pending[key]--
+		}
+	}
+}
+
+// Release ends a reserved attempt without recording an outcome, e.g. when
+// the login stopped on a server error or waits for a second factor
+func (t *LoginThrottle) Release(username, ip string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.release(throttleKeys(username, ip))
+}
+
+// Failure records a failed attempt and locks out the username or IP once
+// it reaches its policy's limit. It ends the attempt's reservation.
+func (t *LoginThrottle) Failure(username, ip string) error {
+	userKey, ipKey := throttleKeys(username, ip)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	defer t.release(userKey, ipKey)
+	now := time.Now()
+
+	for _, record := range []struct {
+		key, kind, subject string
+		policy             ThrottlePolicy
+	}{{userKey, "username", username, t.userPolicy}, {ipKey, "ip", ip, t.ipPolicy}} {
+		attempts, err := t.store.RecordFailure(record.key, now, record.policy.Window)
+		if err != nil {
+			return err
+		}
+		if attempts.Failures < record.policy.MaxFailures {
+			continue
+		}
+
+		// Lock out and start counting afresh once the lockout ends
+		until := now.Add(record.policy.Lockout)
+		if err := t.store.Reset(record.key); err != nil {
+			return err
+		}
+		if err := t.store.Lock(record.key, until); err != nil {
+			return err
+		}
+		if t.OnLockout != nil {
+			t.OnLockout(record.kind, record.subject, until)
+		}
+	}
+	return nil
+}
+
+// Success clears the username's failures after a successful login and ends
+// the attempt's reservation. The IP keeps its count so one valid account
+// cannot mask guessing at others.
+func (t *LoginThrottle) Success(username, ip string) error {
+	userKey, ipKey := throttleKeys(username, ip)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	defer t.release(userKey, ipKey)
+	return t.store.Reset(userKey)
+}
+
+// maxDuration returns the longer of two durations
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ClientIP returns the IP address a request came from. Forwarding headers
+// are ignored since any client can set them.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
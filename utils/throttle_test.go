@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// testPolicy allows 3 failures without backoff, then locks out
+var testPolicy = ThrottlePolicy{
+	MaxFailures: 3,
+	Lockout:     time.Minute,
+	Window:      time.Minute,
+}
+
+func TestThrottleConcurrentAttempts(t *testing.T) {
+	throttle := NewLoginThrottle(NewMemoryAttemptStore(), testPolicy, DefaultIPPolicy)
+
+	// Many parallel guesses all pass the check before any of them fails;
+	// only as many as could fail before a lockout may be reserved
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		reserved int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, err := throttle.Reserve("alice", "10.0.0.1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if wait == 0 {
+				mutex.Lock()
+				reserved++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if reserved != testPolicy.MaxFailures {
+		t.Fatalf("reserved %d attempts, want %d", reserved, testPolicy.MaxFailures)
+	}
+
+	// They all fail, which locks the username out
+	for i := 0; i < reserved; i++ {
+		if err := throttle.Failure("alice", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wait, err := throttle.Reserve("alice", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= testPolicy.Lockout-time.Second {
+		t.Errorf("wait after lockout = %v, want about %v", wait, testPolicy.Lockout)
+	}
+}
+
+func TestThrottleReleaseAndSuccess(t *testing.T) {
+	policy := testPolicy
+	policy.BaseDelay = time.Minute
+	policy.MaxDelay = time.Minute
+	throttle := NewLoginThrottle(NewMemoryAttemptStore(), policy, DefaultIPPolicy)
+
+	reserve := func() time.Duration {
+		t.Helper()
+		wait, err := throttle.Reserve("alice", "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return wait
+	}
+
+	// An attempt in flight holds back the next one for the same username
+	if wait := reserve(); wait != 0 {
+		t.Fatalf("first attempt waits %v", wait)
+	}
+	if wait := reserve(); wait == 0 {
+		t.Fatal("second attempt was reserved while the first is in flight")
+	}
+
+	// Releasing ends the reservation without counting a failure
+	throttle.Release("alice", "10.0.0.1")
+	if wait := reserve(); wait != 0 {
+		t.Fatalf("attempt after release waits %v", wait)
+	}
+
+	// A failure backs off; a success clears it
+	if err := throttle.Failure("alice", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if wait := reserve(); wait == 0 {
+		t.Fatal("attempt right after a failure was reserved")
+	}
+	if err := throttle.Success("alice", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if wait := reserve(); wait != 0 {
+		t.Errorf("attempt after success waits %v", wait)
+	}
+}